@@ -1,30 +1,32 @@
 package main
 
 import (
-	"fmt"
-	"net/http"
+	"github.com/thingforward/slyft-cli/client"
 )
 
 type SlyftApiModelInterface interface {
-	EndPoint() string
 	getName() string
+	delete(c *client.Client) error
 }
 
-func DeleteApiModel(inst SlyftApiModelInterface) {
+func DeleteApiModel(inst SlyftApiModelInterface) error {
 	if inst == nil {
-		return
+		return nil
 	}
 	confirm := askForConfirmation("Are you sure to delete element '" + inst.getName() + "'?")
 	if confirm {
-		resp, err := Do(inst.EndPoint(), "DELETE", nil)
-		defer resp.Body.Close()
-		if err != nil || resp.StatusCode != http.StatusNoContent {
-			fmt.Printf("Something went wrong. Please try again. (ResponseCode: %d)\n", resp.StatusCode)
-			Log.Debug(err)
-		} else {
-			fmt.Println("Was successfully deleted")
+		c, err := authClient()
+		if err == nil {
+			err = inst.delete(c)
 		}
+		if err != nil {
+			return ReportError("Deleting", err)
+		}
+		statusf("Was successfully deleted\n")
+	} else if !interactive() {
+		return ReportError("Deleting", newError(exitUsage, "refusing to delete without --yes in non-interactive mode"))
 	} else {
-		fmt.Println("Good decision!")
+		statusf("Good decision!\n")
 	}
+	return nil
 }
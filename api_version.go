@@ -7,8 +7,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	version "github.com/mcuadros/go-version"
+	"github.com/thingforward/slyft-cli/client"
 )
 
 const CONFIG_JSON_URL = "https://s3-eu-west-1.amazonaws.com/io-slyft-config/slyft-config.json"
@@ -19,48 +25,185 @@ type configJson struct {
 		Max     int `json:"max"`
 		Current int `json:"current"`
 	} `json:"api_version"`
-	EndPoints []struct {
-		Num1 string `json:"1,omitempty"`
-		Num2 string `json:"2,omitempty"`
-		Num3 string `json:"3,omitempty"`
-		Num4 string `json:"4,omitempty"`
-	} `json:"end_points"`
+	// EndPoints map API versions ("1", "2", ...) to backend URLs
+	EndPoints     []map[string]string `json:"end_points"`
 	ClientVersion struct {
 		Latest string   `json:"latest"`
 		Update []string `json:"update"`
 	} `json:"client_version"`
 }
 
+// APIVersion is the API version used for all requests, negotiated with
+// the server in NegotiateAPI.
+var APIVersion = client.DefaultAPIVersion
+
+// endPoint returns the backend URL serving the given API version.
+func (config *configJson) endPoint(version int) string {
+	for _, ep := range config.EndPoints {
+		if url, ok := ep[strconv.Itoa(version)]; ok && url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
 type UpdateCheckResult struct {
 	ShouldUpdate bool
 	MustUpdate   bool
 }
 
-func getConfigJson() (*configJson, error) {
-	config := &configJson{}
-	resp, err := getJson(CONFIG_JSON_URL)
+// UpdateCheckConfig is the "update_check" section of ~/.slyftrc. Each
+// setting can be overridden by an environment variable.
+type UpdateCheckConfig struct {
+	Disabled bool   `json:"disabled,omitempty"` // SLYFT_UPDATE_CHECK=off, the config is still used by NegotiateAPI
+	URL      string `json:"url,omitempty"`      // SLYFT_CONFIG_URL, http(s) URL or local file
+	TTL      string `json:"ttl,omitempty"`      // SLYFT_UPDATE_CHECK_TTL, e.g. "24h"
+}
+
+const defaultUpdateCheckTTL = 24 * time.Hour
+
+// configJsonCache is kept in ~/.slyft-config-cache.json, so the config
+// is fetched once per TTL only, and is available when offline.
+type configJsonCache struct {
+	URL       string          `json:"url"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	Config    json.RawMessage `json:"config"`
+}
+
+func updateCheckConfig() UpdateCheckConfig {
+	var uc UpdateCheckConfig
+	if sr, err := readConfig(); err == nil && sr.UpdateCheck != nil {
+		uc = *sr.UpdateCheck
+	}
+	switch strings.ToLower(os.Getenv("SLYFT_UPDATE_CHECK")) {
+	case "off", "false", "0", "no":
+		uc.Disabled = true
+	case "on", "true", "1", "yes":
+		uc.Disabled = false
+	}
+	if url := os.Getenv("SLYFT_CONFIG_URL"); url != "" {
+		uc.URL = url
+	}
+	if ttl := os.Getenv("SLYFT_UPDATE_CHECK_TTL"); ttl != "" {
+		uc.TTL = ttl
+	}
+	if uc.URL == "" {
+		uc.URL = CONFIG_JSON_URL
+	}
+	return uc
+}
+
+func (uc UpdateCheckConfig) ttl() time.Duration {
+	if uc.TTL == "" {
+		return defaultUpdateCheckTTL
+	}
+	ttl, err := time.ParseDuration(uc.TTL)
 	if err != nil {
-		return config, err
+		Log.Warningf("Invalid update check TTL '%s', using %v", uc.TTL, defaultUpdateCheckTTL)
+		return defaultUpdateCheckTTL
+	}
+	return ttl
+}
+
+func configCacheFile() string {
+	return filepath.FromSlash(portableGetUsersHome() + "/.slyft-config-cache.json")
+}
+
+func readConfigCache() (*configJsonCache, error) {
+	content, err := readFile(configCacheFile())
+	if err != nil {
+		return nil, err
+	}
+	cache := &configJsonCache{}
+	if err := json.Unmarshal(content, cache); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func writeConfigCache(url string, body []byte) {
+	content, err := json.Marshal(&configJsonCache{URL: url, FetchedAt: time.Now(), Config: body})
+	if err == nil {
+		err = writeFileAtomic(configCacheFile(), content, 0600)
+	}
+	if err != nil {
+		Log.Debugf("Unable to cache config: %v", err)
+	}
+}
+
+// fetchConfigJson reads the config from an http(s) URL or a local file.
+func fetchConfigJson(url string) ([]byte, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return readFile(strings.TrimPrefix(url, "file://"))
+	}
+
+	resp, err := getJson(url)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	err = ensureValidResponse(resp)
 	if err != nil {
-		return config, err
+		return nil, err
 	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// loadedConfigJson keeps the config once loaded, it is used by both
+// UpdateCheck and NegotiateAPI.
+var loadedConfigJson struct {
+	url    string
+	config *configJson
+	err    error
+}
+
+// getConfigJson returns the client config, from the cache if it is
+// younger than the TTL. If fetching fails, an outdated cache is used.
+func getConfigJson(uc UpdateCheckConfig) (*configJson, error) {
+	if loadedConfigJson.url != uc.URL {
+		config, err := loadConfigJson(uc)
+		loadedConfigJson.url, loadedConfigJson.config, loadedConfigJson.err = uc.URL, config, err
+	}
+	return loadedConfigJson.config, loadedConfigJson.err
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+func loadConfigJson(uc UpdateCheckConfig) (*configJson, error) {
+	config := &configJson{}
+	cache, cacheErr := readConfigCache()
+	if cacheErr == nil && cache.URL == uc.URL && time.Since(cache.FetchedAt) < uc.ttl() {
+		Log.Debugf("Using cached config from %v", cache.FetchedAt)
+		return config, json.Unmarshal(cache.Config, config)
+	}
 
-	if err := json.Unmarshal(body, &config); err != nil {
+	body, err := fetchConfigJson(uc.URL)
+	if err == nil {
+		err = json.Unmarshal(body, config)
+	}
+	if err != nil {
+		if cacheErr == nil && cache.URL == uc.URL {
+			Log.Debugf("Fetching config failed (%v), using cached config from %v", err, cache.FetchedAt)
+			return config, json.Unmarshal(cache.Config, config)
+		}
 		return config, err
 	}
+
+	writeConfigCache(uc.URL, body)
 	return config, nil
 }
 
+// UpdateCheck tells the user about new versions of the client. It only
+// fails if this version must be updated; if the config can't be fetched
+// the check is skipped.
 func UpdateCheck(appVersion string) error {
-	config, err := getConfigJson()
+	uc := updateCheckConfig()
+	if uc.Disabled {
+		return nil
+	}
+	config, err := getConfigJson(uc)
 	if err != nil {
-		return err
+		Log.Debugf("Skipping update check, unable to get %s: %v", uc.URL, err)
+		return nil
 	}
 	res := &UpdateCheckResult{}
 	if version.Compare(appVersion, config.ClientVersion.Latest, "<") {
@@ -78,6 +221,35 @@ func UpdateCheck(appVersion string) error {
 	return nil
 }
 
+// NegotiateAPI picks the highest API version supported by both the
+// server and this client. Unless a backend was chosen explicitly, the
+// backend serving this version is taken from the config's end_points.
+// This happens even if the update check is disabled, which only turns
+// off the check for newer versions of slyft.
+func NegotiateAPI(backendChosen bool) error {
+	uc := updateCheckConfig()
+	config, err := getConfigJson(uc)
+	if err != nil {
+		Log.Debugf("Using API version %d, unable to get %s: %v", APIVersion, uc.URL, err)
+		return nil
+	}
+
+	version, err := client.NegotiateVersion(config.APIVersion.Min, config.APIVersion.Max)
+	if err != nil {
+		return errors.New(fmt.Sprintf("This version of slyft cannot talk to the server (%v). Please update.", err))
+	}
+	APIVersion = version
+	Log.Debugf("Using API version %d", APIVersion)
+
+	if !backendChosen {
+		if url := config.endPoint(version); url != "" {
+			BackendBaseUrl = url
+			Log.Debugf("Using backend %s", BackendBaseUrl)
+		}
+	}
+	return nil
+}
+
 func displayUpdateCheck(res *UpdateCheckResult) {
 	if !res.ShouldUpdate && !res.MustUpdate {
 		return
@@ -99,13 +271,14 @@ func getJson(url string) (*http.Response, error) {
 		return nil, err
 	}
 	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	client := &http.Client{}
+	// don't hold up the user for long if the network is unreachable
+	client := &http.Client{Timeout: 3 * time.Second}
 	return client.Do(req)
 }
 
 func ensureValidResponse(resp *http.Response) error {
 	if !(resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusOK) {
-		return errors.New(fmt.Sprintf("Server returned no content and status code: %v", resp.StatusCode))
+		return statusError(resp.StatusCode, fmt.Sprintf("Server returned no content and status code: %v", resp.StatusCode))
 	}
 	return nil
 }
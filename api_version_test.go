@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateCheck(t *testing.T) {
+	home, cleanup := tempHome(t)
+	defer cleanup()
+	defer os.Unsetenv("SLYFT_CONFIG_URL")
+	defer func() { loadedConfigJson.url = "" }()
+
+	// unreachable config must not keep the user from working
+	os.Setenv("SLYFT_CONFIG_URL", filepath.Join(home, "missing.json"))
+	if err := UpdateCheck("0.1.0"); err != nil {
+		t.Errorf("Must ignore unreachable config: %v", err)
+	}
+
+	configFile := filepath.Join(home, "slyft-config.json")
+	ioutil.WriteFile(configFile, []byte(`{"client_version": {"latest": "0.3.3", "update": ["0.1.0"]}}`), 0644)
+	os.Setenv("SLYFT_CONFIG_URL", "file://"+configFile)
+	if err := UpdateCheck("0.1.0"); err == nil {
+		t.Error("Must require update for versions listed in update")
+	}
+	if err := UpdateCheck("0.2.0"); err != nil {
+		t.Errorf("Must only suggest update: %v", err)
+	}
+
+	// the cached config is used once the file is gone
+	os.Remove(configFile)
+	loadedConfigJson.url = ""
+	if err := UpdateCheck("0.1.0"); err == nil {
+		t.Error("Must use cached config")
+	}
+
+	os.Setenv("SLYFT_UPDATE_CHECK", "off")
+	defer os.Unsetenv("SLYFT_UPDATE_CHECK")
+	if err := UpdateCheck("0.1.0"); err != nil {
+		t.Errorf("Must skip disabled update check: %v", err)
+	}
+}
+
+func TestNegotiateAPI(t *testing.T) {
+	home, cleanup := tempHome(t)
+	defer cleanup()
+	defer func() {
+		os.Unsetenv("SLYFT_CONFIG_URL")
+		loadedConfigJson.url = ""
+		BackendBaseUrl = "https://api.slyft.io/"
+	}()
+
+	configFile := filepath.Join(home, "slyft-config.json")
+	ioutil.WriteFile(configFile, []byte(`{
+		"api_version": {"min": 1, "max": 2, "current": 2},
+		"end_points": [{"1": "https://v1.slyft.test/"}, {"2": "https://v2.slyft.test/"}]
+	}`), 0644)
+	os.Setenv("SLYFT_CONFIG_URL", configFile)
+
+	if err := NegotiateAPI(true); err != nil {
+		t.Fatalf("Must negotiate: %v", err)
+	}
+	if APIVersion != 1 {
+		t.Errorf("Expected API version 1, got %d", APIVersion)
+	}
+	if BackendBaseUrl == "https://v1.slyft.test/" {
+		t.Error("Must keep an explicitly chosen backend")
+	}
+
+	// disabling the update check does not disable negotiation
+	os.Setenv("SLYFT_UPDATE_CHECK", "off")
+	defer os.Unsetenv("SLYFT_UPDATE_CHECK")
+	if err := NegotiateAPI(false); err != nil {
+		t.Fatalf("Must negotiate: %v", err)
+	}
+	if BackendBaseUrl != "https://v1.slyft.test/" {
+		t.Errorf("Must pick backend from end_points, got %s", BackendBaseUrl)
+	}
+}
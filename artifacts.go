@@ -0,0 +1,207 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+)
+
+// latestBuild returns the most recent processed build job of p.
+func latestBuild(p *Project) (*Job, error) {
+	jobs, err := getJobs(p)
+	if err != nil {
+		return nil, err
+	}
+	var latest *Job
+	for i := range jobs {
+		j := &jobs[i]
+		if j.Kind == "build" && j.Status == "processed" && (latest == nil || j.CreatedAt.After(latest.CreatedAt) ||
+			j.CreatedAt.Equal(latest.CreatedAt) && j.ID > latest.ID) {
+			latest = j
+		}
+	}
+	if latest == nil {
+		return nil, newError(exitNotFound, "Project %s has no finished build", p.Name)
+	}
+	return latest, nil
+}
+
+// archiveExtensions are the archives `slyft project artifacts --extract`
+// unpacks.
+var archiveExtensions = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+func isArchive(file string) bool {
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(strings.ToLower(file), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractArchive unpacks the zip or (gzipped) tar archive file into dir.
+// Entries pointing outside of dir are rejected. Existing files are only
+// overwritten with force, or if the user confirms it.
+func extractArchive(file, dir string, force bool) error {
+	if strings.HasSuffix(strings.ToLower(file), ".zip") {
+		return extractZip(file, dir, force)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if !strings.HasSuffix(strings.ToLower(file), ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := extractDir(dir, hdr.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(dir, hdr.Name, tr, os.FileMode(hdr.Mode), force); err != nil {
+				return err
+			}
+		default:
+			Log.Debugf("Skipping %s in %s, type %c", hdr.Name, file, hdr.Typeflag)
+		}
+	}
+}
+
+func extractZip(file, dir string, force bool) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			if err := extractDir(dir, zf.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		if !zf.Mode().IsRegular() {
+			Log.Debugf("Skipping %s in %s, mode %v", zf.Name, file, zf.Mode())
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = extractFile(dir, zf.Name, r, zf.Mode(), force)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractDir(dir, name string) error {
+	path, err := assetFilePath(dir, strings.TrimSuffix(name, "/"))
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0755)
+}
+
+func extractFile(dir, name string, r io.Reader, mode os.FileMode, force bool) error {
+	path, err := assetFilePath(dir, name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(path); err == nil && !force &&
+		!askForConfirmation(fmt.Sprintf("%s already exists. Do you want to overwrite it?", path)) {
+		return newError(exitConflict, "%s already exists, use --force to overwrite it", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	return writeAtomic(path, r, perm, nil)
+}
+
+func projectArtifacts(cmd *cli.Cmd) {
+	cmd.Spec = "[--project] [--job] [--output-dir] [--extract] [--force] [--parallel]"
+	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	jobID := cmd.IntOpt("job", 0, "ID of the build job (default: the latest finished build)")
+	outputDir := cmd.StringOpt("output-dir", ".", "Directory to save the artifacts in")
+	extract := cmd.BoolOpt("extract x", false, "Unpack zip and tar archives into the output directory (default: false)")
+	force := cmd.BoolOpt("force f", false, "Overwrite files even if they were changed locally (default: false)")
+	parallel := cmd.IntOpt("parallel j", 1, "Number of artifacts to download at the same time")
+
+	cmd.Action = action(func() error {
+		*name = strings.TrimSpace(*name)
+		if *name == "" {
+			*name, _ = ReadProjectLock()
+		}
+		p, err := chooseProject(*name, "Download artifacts of: ")
+		if err != nil {
+			return ReportError("Choosing the project", err)
+		}
+
+		var job *Job
+		if *jobID != 0 {
+			job, err = getJob(p, *jobID)
+		} else {
+			job, err = latestBuild(p)
+		}
+		if err != nil {
+			return ReportError("Finding the build", err)
+		}
+		if job.Status != "processed" {
+			return ReportError("Downloading artifacts", newError(exitIncomplete, "Job %d is not finished yet (%s)", job.ID, job.Status))
+		}
+		if len(job.Results.ResultAssets) == 0 {
+			statusf("Job %d has no artifacts\n", job.ID)
+			return nil
+		}
+
+		results := forEachFile(job.Results.ResultAssets, *parallel, func(artifact string) error {
+			if err := getAssetAndSaveToFile(artifact, *outputDir, p, *force); err != nil {
+				return err
+			}
+			if !*extract || !isArchive(artifact) {
+				return nil
+			}
+			file, _ := assetFilePath(*outputDir, artifact)
+			if err := extractArchive(file, *outputDir, *force); err != nil {
+				return ReportError("Extracting "+artifact, err)
+			}
+			statusf("Extracted %s\n", file)
+			return nil
+		})
+		return displayFileResults(results)
+	})
+}
@@ -1,40 +1,50 @@
 package main
 
 import (
-	"encoding/base64"
-	"encoding/json"
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
 
 	cli "github.com/jawher/mow.cli"
+	"github.com/thingforward/slyft-cli/client"
 )
 
-type Asset struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	ProjectId   int       `json:"project_id"`
-	ProjectName string    `json:"project_name"`
-	Origin      string    `json:"origin"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+// Asset is a client.Asset as shown and chosen by the commands.
+type Asset client.Asset
+
+// toAssets converts the assets returned by the client.
+func toAssets(assets []client.Asset) []Asset {
+	converted := make([]Asset, len(assets))
+	for i := range assets {
+		converted[i] = Asset(assets[i])
+	}
+	return converted
 }
 
 func (a *Asset) getName() string {
 	return a.Name
 }
 
+func (a *Asset) delete(c *client.Client) error {
+	return c.DeleteAsset((*client.Asset)(a))
+}
+
 func (a *Asset) Display() { // String?
 	if a == nil {
 		return
 	}
 
+	if structuredOutput() {
+		displayStructured(a)
+		return
+	}
+
 	data := [][]string{
 		[]string{"Key", "Value"},
 		[]string{"Name", a.Name},
@@ -51,6 +61,11 @@ func (a *Asset) Display() { // String?
 }
 
 func DisplayAssets(assets []Asset) {
+	if structuredOutput() {
+		displayStructured(assets)
+		return
+	}
+
 	if len(assets) == 0 {
 		fmt.Println("No assets found")
 		return
@@ -71,38 +86,35 @@ func DisplayAssets(assets []Asset) {
 	fmt.Fprintf(os.Stdout, markdownTable(&data))
 }
 
-func extractAssetsFromBody(body []byte) ([]Asset, error) {
-	Log.Debugf("body=%v", string(body))
-	assets := make([]Asset, 0)
-	if err := json.Unmarshal(body, &assets); err != nil {
+// fetchAssets returns the assets of the project with projectID, or of
+// all projects if it is 0.
+func fetchAssets(projectID int) ([]Asset, error) {
+	c, err := authClient()
+	if err != nil {
 		return nil, err
 	}
-	return assets, nil
-}
-
-func extractAssetFromBody(body []byte) ([]Asset, error) {
-	Log.Debugf("body=%v", string(body))
-	a := &Asset{}
-	if err := json.Unmarshal(body, &a); err != nil {
-		return nil, err
+	var assets []client.Asset
+	if projectID == 0 {
+		assets, err = c.ListAllAssets()
+	} else {
+		assets, err = c.ListAssets(projectID)
 	}
-	return []Asset{*a}, nil
-}
-
-func chooseAsset(endpoint string, askUser bool, message string, count int) (*Asset, error) {
-	resp, err := Do(endpoint, "GET", nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	assets, err := extractAssetFromResponse(resp, http.StatusOK, true)
+	return toAssets(assets), nil
+}
 
+// chooseAsset lets the user choose among the assets of the project with
+// projectID, or of all projects if it is 0.
+func chooseAsset(projectID int, askUser bool, message string, count int) (*Asset, error) {
+	assets, err := fetchAssets(projectID)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(assets) == 0 {
-		return nil, errors.New("No assets found.")
+		return nil, newError(exitNotFound, "No assets found.")
 	}
 	Log.Debugf("assets=%+v", assets)
 
@@ -124,32 +136,38 @@ func chooseAsset(endpoint string, askUser bool, message string, count int) (*Ass
 		return nil, nil
 	}
 
+	if !interactive() {
+		candidates := make([]string, 0, len(assets))
+		for _, a := range assets {
+			candidates = append(candidates, fmt.Sprintf("%s (project %s)", a.Name, a.ProjectName))
+		}
+		return nil, ambiguousChoice("asset", candidates)
+	}
+
 	choice, err := ReadUserIntInput(message)
 	if err != nil {
 		return nil, err
 	}
 
 	if choice > len(assets) {
-		return nil, errors.New("Plese choose a number from the first column")
+		return nil, newError(exitUsage, "Plese choose a number from the first column")
 	}
 
 	return &assets[choice-1], nil
 }
 
-type AssetPost struct {
-	Name  string `json:"name"`
-	Asset string `json:"asset"` // note: this will be base64 string
-}
-
-type AssetParam struct {
-	Asset AssetPost `json:"asset"`
-}
+// assetUpload is the checked content of a file, to be uploaded as asset.
+type assetUpload struct {
+	name     string
+	mimeType string
+	data     []byte
 
-type AssetNameString struct {
-	AssetNameString string `json:"asset_name"`
+	// digest of the uploaded content, see assetDigest
+	digest string
 }
 
-func creatAssetParam(file string) (*AssetParam, error) {
+// readNamedAsset reads file to be uploaded as asset called name.
+func readNamedAsset(file, name string) (*assetUpload, error) {
 	// read the file content (use ioutil)
 	bytes, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -161,152 +179,185 @@ func creatAssetParam(file string) (*AssetParam, error) {
 		return nil, err
 	}
 
-	return &AssetParam{
-		AssetPost{
-			Name:  file,
-			Asset: "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(bytes),
-		},
-	}, nil
+	return &assetUpload{name: name, mimeType: mimeType, data: bytes, digest: assetDigest(bytes)}, nil
 }
 
-func putAsset(id int, assetParam *AssetParam, p *Project) error {
-	resp, err := Do(p.AssetUrl(id), "PUT", assetParam)
+// postAsset creates the asset. If its name is taken, the existing asset
+// is returned with an error for which client.IsConflict is true.
+func postAsset(u *assetUpload, p *Project) (*Asset, error) {
+	c, err := authClient()
 	if err != nil {
-		ReportError("Contacting server", err)
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
+	a, err := c.UploadAsset(p.ID, u.name, u.mimeType, u.data)
+	return (*Asset)(a), err
+}
 
-	if resp.StatusCode != http.StatusNoContent {
-		Log.Debugf("resp.Code=%#v / expected=%d", resp.StatusCode, http.StatusNoContent)
-		return errors.New(fmt.Sprintf("Failed with the wrong code: %v. (expected %v)\n", resp.StatusCode, http.StatusNoContent))
+func putAsset(id int, u *assetUpload, p *Project) error {
+	c, err := authClient()
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return c.UpdateAsset(p.ID, id, u.name, u.mimeType, u.data)
 }
 
 func readFileAndPostAsset(file string, p *Project, forceFlag bool) error {
-	fmt.Printf("Saving asset %s\n", file)
+	return readFileAndPostNamedAsset(".", file, file, p, forceFlag)
+}
 
-	assetParam, err := creatAssetParam(file)
-	if err != nil {
-		ReportError("Creating request", err)
-		return err
+// readFileAndPostNamedAsset uploads file as asset called name, which is
+// recorded in the state of dir. Files found below a directory are named
+// after their path relative to it, see walkAssetFiles.
+func readFileAndPostNamedAsset(dir, file, name string, p *Project, forceFlag bool) error {
+	statusf("Saving asset %s\n", name)
+
+	if largeAsset(file) {
+		return readFileAndPostLargeAsset(dir, file, name, p, forceFlag)
 	}
 
-	resp, err := Do(p.AssetsUrl(), "POST", assetParam)
+	upload, err := readNamedAsset(file, name)
 	if err != nil {
-		ReportError("Contacting server", err)
-		return err
+		return ReportError("Creating request", err)
 	}
-	defer resp.Body.Close()
-	assets, err := extractAssetFromResponse(resp, http.StatusCreated, false)
-	if err != nil {
-		if len(assets) == 1 {
-			// we have a duplicate.
-			okToUpdate := false
-			if forceFlag {
-				// overwrite
-				okToUpdate = true
-			} else {
-				// ask
-				okToUpdate = askForConfirmation("The asset already exists. Do you want to overwrite it?")
-			}
 
-			// we have a duplicate.
-			if okToUpdate {
-				assets[0].Display()
-				return putAsset(assets[0].ID, assetParam, p)
+	a, err := postAsset(upload, p)
+	if client.IsConflict(err) && a != nil {
+		// we have a duplicate.
+		okToUpdate := false
+		if forceFlag {
+			// overwrite
+			okToUpdate = true
+		} else {
+			// ask
+			okToUpdate = askForConfirmation("The asset already exists. Do you want to overwrite it?")
+		}
+
+		if okToUpdate {
+			if !structuredOutput() {
+				a.Display()
 			}
-			return nil
+			if err := putAsset(a.ID, upload, p); err != nil {
+				return ReportError("Updating asset", err)
+			}
+			recordUpload(dir, p.ID, name, upload.digest)
 		}
-		ReportError("Creating asset", err)
-		return err
+		return nil
+	}
+	if err != nil {
+		return ReportError("Creating asset", err)
 	}
 
-	if len(assets) == 1 {
-		assets[0].Display()
+	// with --output json or yaml, the results of all files are displayed
+	if !structuredOutput() {
+		a.Display()
 	}
+	recordUpload(dir, p.ID, name, upload.digest)
 	return nil
 }
 
-func getAssetAndSaveToFile(file string, p *Project) {
-	resp, err := Do(p.AssetstoreUrl(), "GET", &AssetNameString{file})
+// assetFilePath returns the path of the file for asset name below dir.
+// Names pointing outside of dir are rejected.
+func assetFilePath(dir, name string) (string, error) {
+	sep := string(filepath.Separator)
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if name == "" || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+sep) ||
+		filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || strings.HasPrefix(clean, sep) {
+		return "", newError(exitUsage, "Refusing to write asset %s outside of %s", name, dir)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// getAssetAndSaveToFile downloads asset name into dir, creating the
+// directories in its path. An existing file is only overwritten if it was
+// not changed since it was last uploaded or downloaded, or with force.
+func getAssetAndSaveToFile(name, dir string, p *Project, force bool) error {
+	file, err := assetFilePath(dir, name)
 	if err != nil {
-		ReportError("Downloading asset", err)
-		return
+		return ReportError("Downloading asset", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == 200 {
-		// stream body to file of this name
-		out, err := os.Create(file)
-		if err != nil {
-			ReportError("Creating asset file", err)
-			return
+	c, err := authClient()
+	if err != nil {
+		return ReportError("Downloading asset", err)
+	}
+	content, err := c.OpenAsset(p.ID, name)
+	if err != nil {
+		return ReportError("Downloading asset", err)
+	}
+	defer content.Close()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return ReportError("Creating asset directory", err)
+	}
+
+	// stream body to a temporary file, which replaces file when complete
+	h := sha256.New()
+	var digest string
+	err = writeAtomic(file, io.TeeReader(content, h), 0644, func() error {
+		digest = hex.EncodeToString(h.Sum(nil))
+		if force {
+			return nil
+		}
+		local, err := fileDigest(file)
+		if os.IsNotExist(err) || local == digest {
+			return nil
 		}
-		defer out.Close()
-		_, err = io.Copy(out, resp.Body)
 		if err != nil {
-			ReportError("Writing asset file", err)
-			return
+			return err
 		}
-		fmt.Printf("Downloaded %s\n", file)
-	} else {
-		ReportError("Downloading asset", nil)
+		if local != readState(dir).project(p.ID).digest(name) {
+			return newError(exitConflict, "%s has local modifications, use --force to overwrite it", file)
+		}
+		return nil
+	})
+	if err != nil {
+		return ReportError("Writing asset file", err)
 	}
+	updateState(dir, p.ID, func(ps *ProjectState) {
+		ps.synced(name, digest)
+	})
+	statusf("Downloaded %s\n", file)
+	return nil
 }
 
 func getAllAssets(p *Project) ([]Asset, error) {
-	resp, err := Do(p.AssetsUrl(), "GET", nil)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	assets, err := extractAssetFromResponse(resp, http.StatusOK, true)
-
+	assets, err := fetchAssets(p.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(assets) == 0 {
-		return nil, errors.New("No assets found.")
+		return nil, newError(exitNotFound, "No assets found.")
 	}
 
 	return assets, nil
 }
 
-func removeSingleFileFromAsset(assets []Asset, file string, p *Project) {
+func removeSingleFileFromAsset(assets []Asset, file string, p *Project) error {
 
 	for _, asset := range assets {
 		if asset.Name == file {
-			fmt.Printf("Deleting asset %s\n", file)
+			statusf("Deleting asset %s\n", file)
 
-			resp, err := Do(asset.EndPoint(), "DELETE", &AssetNameString{file})
-			Log.Debugf("resp=%#v", resp)
+			c, err := authClient()
 			if err != nil {
-				Log.Debugf("err=%#v", err)
-				ReportError("Removing asset", err)
-				return
+				return ReportError("Removing asset", err)
 			}
-			defer resp.Body.Close()
-
-			if err != nil || resp.StatusCode != http.StatusNoContent {
-				if resp.StatusCode == http.StatusNotFound {
-					fmt.Printf("Unable to delete asset with name %s\n", file)
+			if err := asset.delete(c); err != nil {
+				if client.IsNotFound(err) {
+					statusf("Unable to delete asset with name %s\n", file)
 				} else {
-					fmt.Printf("Something went wrong. Please try again. (ResponseCode: %d)\n", resp.StatusCode)
+					statusf("Something went wrong. Please try again. (%v)\n", err)
 				}
-			} else {
-				fmt.Println("Was successfully deleted")
+				return err
 			}
-
-			return
+			statusf("Was successfully deleted\n")
+			return nil
 		}
 	}
 
-	fmt.Printf("Unable to delete asset with name %s\n", file)
-
+	statusf("Unable to delete asset with name %s\n", file)
+	return newError(exitNotFound, "No asset named %s", file)
 }
 
 func listAssets(cmd *cli.Cmd) {
@@ -314,11 +365,13 @@ func listAssets(cmd *cli.Cmd) {
 	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
 	all := cmd.BoolOpt("all a", false, "Fetch details of all your assets (do not combine with -p)")
 
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
 		*name = strings.TrimSpace(*name)
 		if *all {
-			chooseAsset("/v1/assets", false, "", 0)
-			return
+			if _, err := chooseAsset(0, false, "", 0); err != nil {
+				return ReportError("Listing the assets", err)
+			}
+			return nil
 		} else {
 			if *name == "" {
 				*name, _ = ReadProjectLock()
@@ -328,23 +381,33 @@ func listAssets(cmd *cli.Cmd) {
 		// first get the project, then get the pid, and make the call.
 		p, err := chooseProject(*name, "Which project's assets would you like to see: ")
 		if err != nil {
-			ReportError("Choosing the project", err)
-			return
+			return ReportError("Choosing the project", err)
 		}
-		if _, err = chooseAsset(p.AssetsUrl(), false, "", 0); err != nil {
-			ReportError("Choosing the asset", err)
+		if _, err = chooseAsset(p.ID, false, "", 0); err != nil {
+			return ReportError("Choosing the asset", err)
 		}
-	}
+		return nil
+	})
 }
 
 func addAsset(cmd *cli.Cmd) {
-	cmd.Spec = "[--project] [--file] [INPUTFILES...]"
+	cmd.LongDesc = `Add asset to a project
+
+Files that are not well-formed JSON, YAML or RAML are not uploaded.
+Problems with the structure of OpenAPI specifications, JSON Schemas and
+RAML documents are printed as warnings, but the files are uploaded
+anyway; use ` + "`slyft asset check`" + ` to make them fail.`
+	cmd.Spec = "[--project] [--parallel] [--recursive] [--include]... [--exclude]... [--file] [INPUTFILES...]"
 	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	parallel := cmd.IntOpt("parallel j", 1, "Number of files to upload at the same time")
+	recursive := cmd.BoolOpt("recursive r", false, "Upload the files in directories and their subdirectories")
+	include := cmd.StringsOpt("include i", nil, "Only upload files matching this glob when recursing (default: JSON, YAML and RAML files)")
+	exclude := cmd.StringsOpt("exclude x", nil, "Do not upload files matching this glob when recursing")
 	// --file is kept as documentation relates on it, but will be deprecated
 	file := cmd.StringOpt("file f", "", "path to the file which you want as an asset")
 	files := cmd.StringsArg("INPUTFILES", nil, "Multiple files to upload as assets")
 
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
 		*name = strings.TrimSpace(*name)
 
 		if *name == "" {
@@ -354,52 +417,74 @@ func addAsset(cmd *cli.Cmd) {
 		// first get the project, then get the pid, and make the call.
 		p, err := chooseProject(*name, "Add asset to: ")
 		if err != nil {
-			ReportError("Choosing the project", err)
-			return
+			return ReportError("Choosing the project", err)
 		}
 
-		didProcessSomething := false
+		uploads := make([]string, 0)
+		// directories which could not be read fail the command as well
+		var unreadable error
+		// files found in directories, with the directory and asset name
+		type namedFile struct{ dir, name string }
+		named := make(map[string]namedFile)
 		if file != nil && *file != "" {
-			*file = strings.TrimSpace(*file)
-			err := readFileAndPostAsset(*file, p, false)
-			if err == nil {
-				didProcessSomething = true
-			}
+			uploads = append(uploads, strings.TrimSpace(*file))
 		}
 		if files != nil {
+			filter := &fileFilter{*include, *exclude}
 			for _, singleFile := range *files {
-				f, err := os.Open(singleFile)
-				fi, err := f.Stat()
-				defer f.Close()
-				switch {
-				case err != nil:
-					fmt.Printf("Unable to read from %s, skipping\n", singleFile)
-				case fi.IsDir():
-					fmt.Printf("Is a directory: %s, skipping\n", singleFile)
-				default:
-					fmt.Printf("Uploading %s ...\n", singleFile)
-					err := readFileAndPostAsset(singleFile, p, false)
-					if err == nil {
-						didProcessSomething = true
+				fi, err := os.Stat(singleFile)
+				if err != nil || !fi.IsDir() {
+					uploads = append(uploads, singleFile)
+					continue
+				}
+				if !*recursive {
+					statusf("Is a directory: %s, skipping (use --recursive to upload its files)\n", singleFile)
+					continue
+				}
+				// files below the directory are named like sync does
+				found, err := walkAssetFiles(singleFile, filter)
+				if err != nil {
+					if err = ReportError("Reading "+singleFile, err); unreadable == nil {
+						unreadable = err
 					}
+					continue
+				}
+				for _, rel := range sortedKeys(found) {
+					uploads = append(uploads, found[rel])
+					named[found[rel]] = namedFile{singleFile, rel}
 				}
 			}
 		}
 
-		if didProcessSomething == false {
-			fmt.Println("Need to specify --file or give valid files as arguments. Did not upload anything")
+		if len(uploads) == 0 {
+			statusf("Need to specify --file or give valid files as arguments. Did not upload anything\n")
+			return unreadable
 		}
 
-	}
+		results := forEachFile(uploads, *parallel, func(singleFile string) error {
+			statusf("Uploading %s ...\n", singleFile)
+			if n, ok := named[singleFile]; ok {
+				return readFileAndPostNamedAsset(n.dir, singleFile, n.name, p, false)
+			}
+			return readFileAndPostAsset(singleFile, p, false)
+		})
+		if err := displayFileResults(results); unreadable == nil {
+			return err
+		}
+		return unreadable
+	})
 }
 
 func getAsset(cmd *cli.Cmd) {
-	cmd.Spec = "[--project] [--file] [FILES...]"
+	cmd.Spec = "[--project] [--parallel] [--output-dir] [--force] [--file] [FILES...]"
 	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	parallel := cmd.IntOpt("parallel j", 1, "Number of assets to download at the same time")
+	outputDir := cmd.StringOpt("output-dir", ".", "Directory to save the assets in, keeping the directories in their names")
+	force := cmd.BoolOpt("force", false, "Overwrite files even if they were changed locally (default: false)")
 	file := cmd.StringOpt("file f", "", "name of the asset to be downloaded")
 	files := cmd.StringsArg("FILES", nil, "Multiple assets to download")
 
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
 		*name = strings.TrimSpace(*name)
 
 		if *name == "" {
@@ -408,30 +493,26 @@ func getAsset(cmd *cli.Cmd) {
 		// first get the project, then get the pid, and make the call.
 		p, err := chooseProject(*name, "Download asset from: ")
 		if err != nil {
-			ReportError("Choosing the project", err)
-			return
+			return ReportError("Choosing the project", err)
 		}
 
-		didProcessSomething := false
+		downloads := make([]string, 0)
 		if file != nil && *file != "" {
-			*file = strings.TrimSpace(*file)
-			getAssetAndSaveToFile(*file, p)
-			didProcessSomething = true
+			downloads = append(downloads, strings.TrimSpace(*file))
 		}
 		if files != nil {
-			for _, singleFile := range *files {
-				getAssetAndSaveToFile(singleFile, p)
-				didProcessSomething = true
-			}
+			downloads = append(downloads, *files...)
 		}
-		if didProcessSomething == false {
-			fmt.Println("Need to specify --file or give valid files as arguments. Did not download anything")
+		if len(downloads) == 0 {
+			statusf("Need to specify --file or give valid files as arguments. Did not download anything\n")
+			return nil
 		}
-	}
-}
 
-func (ass *Asset) EndPoint() string {
-	return fmt.Sprintf("/v1/projects/%d/assets/%d", ass.ProjectId, ass.ID)
+		results := forEachFile(downloads, *parallel, func(singleFile string) error {
+			return getAssetAndSaveToFile(singleFile, *outputDir, p, *force)
+		})
+		return displayFileResults(results)
+	})
 }
 
 func removeAsset(cmd *cli.Cmd) {
@@ -440,7 +521,7 @@ func removeAsset(cmd *cli.Cmd) {
 	count := cmd.IntOpt("count n", 0, "Choose from the last 'count' assets of the project (if project is not specified, select from all)")
 	files := cmd.StringsArg("FILES", nil, "Name(s) of files to delete from asset list")
 
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
 		*name = strings.TrimSpace(*name)
 		if *name == "" {
 			*name, _ = ReadProjectLock()
@@ -449,46 +530,44 @@ func removeAsset(cmd *cli.Cmd) {
 		var ass *Asset
 		var err error
 		if *name == "" {
-			ass, err = chooseAsset("/v1/assets", true, "Which one shall be deleted: ", *count)
-			if err == nil {
-				DeleteApiModel(ass)
-			} else {
-				ReportError("Unable to choose/delete asset(s)", err)
+			ass, err = chooseAsset(0, true, "Which one shall be deleted: ", *count)
+			if err != nil {
+				return ReportError("Unable to choose/delete asset(s)", err)
 			}
+			return DeleteApiModel(ass)
 		} else {
 			// first get the project, then get the pid, and make the call.
 			p, err2 := chooseProject(*name, "Which project's assets would you like to see: ")
 			if err2 != nil {
-				ReportError("Choosing the project", err)
-				return
+				return ReportError("Choosing the project", err2)
 			}
 
 			if files != nil && len(*files) > 0 {
 				assets, err := getAllAssets(p)
-				if err == nil {
-					// locate and delete files
-					for _, singleFile := range *files {
-						removeSingleFileFromAsset(assets, singleFile, p)
+				if err != nil {
+					return ReportError("Querying assets", err)
+				}
+				// locate and delete files, failing with the first failure
+				var first error
+				for _, singleFile := range *files {
+					if err := removeSingleFileFromAsset(assets, singleFile, p); err != nil && first == nil {
+						first = err
 					}
-				} else {
-					Log.Debugf("%#v", err)
-					fmt.Println("Error querying assets.")
 				}
+				return first
 			} else {
 				// choose interactive
-				ass, err = chooseAsset(p.AssetsUrl(), true, "Which one shall be deleted: ", *count)
+				ass, err = chooseAsset(p.ID, true, "Which one shall be deleted: ", *count)
 
 				if err != nil {
-					ReportError("Choosing the asset", err)
-					return
+					return ReportError("Choosing the asset", err)
 				}
 				Log.Debugf("Choosen asset %#v", ass)
 
-				DeleteApiModel(ass)
+				return DeleteApiModel(ass)
 			}
 		}
-
-	}
+	})
 }
 
 func updateAssets(cmd *cli.Cmd) {
@@ -496,7 +575,7 @@ func updateAssets(cmd *cli.Cmd) {
 	proj := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
 	forceOpt := cmd.BoolOpt("force f", false, "If set, do not ask when overwriting (default: false)")
 
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
 		name := *proj
 		name = strings.TrimSpace(name)
 
@@ -504,25 +583,21 @@ func updateAssets(cmd *cli.Cmd) {
 			var err error
 			name, err = ReadProjectLock()
 			if err != nil {
-				ReportError("no --project specified, no project lock found", err)
-				return
+				return ReportError("no --project specified, no project lock found", err)
 			}
 		}
 
-		endpoint := "/v1/assets"
-		resp, err := Do(endpoint, "GET", nil)
+		assets, err := fetchAssets(0)
 		if err != nil {
-			return
-		}
-		defer resp.Body.Close()
-		assets, err := extractAssetFromResponse(resp, http.StatusOK, true)
-
-		if err != nil || len(assets) == 0 {
-			return
+			return ReportError("Querying assets", err)
 		}
 
 		assetTable := [][]string{[]string{"ID", "Name", "ProjectId", "ProjectName", "Origin", "CreatedAt", "UpdatedAt", "Status"}}
 		rows := 0
+		updated := make([]Asset, 0)
+		state := readState(".")
+		// the first failure, the others are only printed
+		var first error
 
 		for _, a := range assets {
 
@@ -531,51 +606,55 @@ func updateAssets(cmd *cli.Cmd) {
 				continue
 			}
 
-			b_updateAvail, err_update := updateAvailable(a.Name, a.UpdatedAt)
+			b_updateAvail, err_update := assetChanged(a.Name, &a, state.project(a.ProjectId))
 			if err_update != nil {
-				fmt.Printf("Unable to check update for %s (%s)\n", a.Name, err_update)
+				statusf("Unable to check update for %s (%s)\n", a.Name, err_update)
+				// assets without a local file are not ours to update
+				if !os.IsNotExist(err_update) && first == nil {
+					first = err_update
+				}
 				continue
 			}
 			if b_updateAvail == false {
-				fmt.Printf("%s is up-to-date (%s)\n", a.Name, a.UpdatedAt)
+				statusf("%s is up-to-date (%s)\n", a.Name, a.UpdatedAt)
 				continue
 			}
 
 			p, err := FindProjectById(a.ProjectId)
 			if err != nil {
-				fmt.Printf("Project ID %d not found\n", a.ProjectId)
+				statusf("Project ID %d not found\n", a.ProjectId)
+				if first == nil {
+					first = err
+				}
 				continue
 			}
 
 			err = readFileAndPostAsset(a.Name, p, *forceOpt)
 			if err != nil {
-				fmt.Println("Error on readFileAndPostAsset")
+				statusf("Error on readFileAndPostAsset\n")
+				if first == nil {
+					first = err
+				}
 				continue
 			}
 
 			row := []string{strconv.Itoa(a.ID), a.Name, strconv.Itoa(a.ProjectId), a.ProjectName, a.Origin, a.CreatedAt.String(), a.UpdatedAt.String(), "Update"}
 			assetTable = append(assetTable, row)
+			updated = append(updated, a)
 			rows++
 		}
 
+		if structuredOutput() {
+			displayStructured(updated)
+			return first
+		}
 		if rows == 0 {
-			return
+			return first
 		}
 
-		listAssets(cmd)
-
-	}
-}
-
-func updateAvailable(name string, updatedAt time.Time) (bool, error) {
-	info, err := os.Stat(name)
-	if err != nil {
-		return false, err
-	}
-	if info.ModTime().After(updatedAt) == false {
-		return false, nil
-	}
-	return true, nil
+		fmt.Fprint(os.Stdout, markdownTable(&assetTable))
+		return first
+	})
 }
 
 func RegisterAssetRoutes(proj *cli.Cmd) {
@@ -586,4 +665,6 @@ func RegisterAssetRoutes(proj *cli.Cmd) {
 	proj.Command("get g", "Download a single asset", getAsset)
 	proj.Command("delete d", "Remove and asset from a project", removeAsset)
 	proj.Command("update u", "Update assets for a project", updateAssets)
+	proj.Command("sync s", "Sync a directory with the assets of a project", syncAssets)
+	proj.Command("check c", "Check asset files locally, without uploading them", checkAssets)
 }
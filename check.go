@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jawher/mow.cli"
+)
+
+// CheckResult lists the problems found in a file by `slyft asset check`.
+type CheckResult struct {
+	File     string    `json:"file"`
+	Problems []Problem `json:"problems"`
+}
+
+// checkFiles runs preflight on files. Directories are searched for asset
+// files like `slyft asset add --recursive` does.
+func checkFiles(files []string) []CheckResult {
+	results := make([]CheckResult, 0, len(files))
+	for _, file := range files {
+		if fi, err := os.Stat(file); err == nil && fi.IsDir() {
+			found, err := walkAssetFiles(file, &fileFilter{})
+			if err != nil {
+				results = append(results, CheckResult{file, []Problem{{File: file, Message: err.Error()}}})
+				continue
+			}
+			for _, rel := range sortedKeys(found) {
+				results = append(results, checkFile(filepath.ToSlash(found[rel])))
+			}
+			continue
+		}
+		results = append(results, checkFile(file))
+	}
+	return results
+}
+
+func checkFile(file string) CheckResult {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return CheckResult{file, []Problem{{File: file, Message: err.Error()}}}
+	}
+	return CheckResult{file, checkAsset(content, file)}
+}
+
+func displayCheckResults(results []CheckResult) {
+	if structuredOutput() {
+		displayStructured(results)
+		return
+	}
+
+	problems, failed := 0, 0
+	for _, r := range results {
+		if len(r.Problems) == 0 {
+			fmt.Printf("%s: ok\n", r.File)
+			continue
+		}
+		failed++
+		problems += len(r.Problems)
+		for _, p := range r.Problems {
+			fmt.Println(p.String())
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%d problem(s) in %d of %d file(s).\n", problems, failed, len(results))
+	}
+}
+
+func checkAssets(cmd *cli.Cmd) {
+	cmd.LongDesc = `Check asset files locally, without uploading them
+
+Unlike ` + "`slyft asset add`" + `, which only warns about problems with the
+structure of OpenAPI specifications, JSON Schemas and RAML documents,
+the check fails on every problem it finds.`
+	cmd.Spec = "FILES..."
+	files := cmd.StringsArg("FILES", nil, "Asset files, or directories holding them, to check")
+
+	cmd.Action = action(func() error {
+		results := checkFiles(*files)
+		displayCheckResults(results)
+		for _, r := range results {
+			if len(r.Problems) > 0 {
+				return newError(exitValidation, "%s has problems", r.File)
+			}
+		}
+		return nil
+	})
+}
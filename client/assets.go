@@ -0,0 +1,126 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type Asset struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	ProjectId   int       `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	Origin      string    `json:"origin"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type assetPost struct {
+	Name  string `json:"name"`
+	Asset string `json:"asset"` // note: this will be base64 string
+}
+
+type assetParam struct {
+	Asset assetPost `json:"asset"`
+}
+
+type assetNameString struct {
+	AssetNameString string `json:"asset_name"`
+}
+
+func AssetsPath(projectID int) string {
+	return ProjectPath(projectID) + "/assets"
+}
+
+func AssetPath(projectID, assetID int) string {
+	return fmt.Sprintf("%s/%d", AssetsPath(projectID), assetID)
+}
+
+func AssetstorePath(projectID int) string {
+	return ProjectPath(projectID) + "/assetstore"
+}
+
+// DataURI encodes an asset as data URI, the format the server expects
+// in asset uploads.
+func DataURI(mimeType string, data []byte) string {
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// ListAssets returns the assets of a project.
+func (c *Client) ListAssets(projectID int) ([]Asset, error) {
+	assets := make([]Asset, 0)
+	err := c.call(c.APIPath(AssetsPath(projectID)), "GET", nil, http.StatusOK, &assets)
+	return assets, err
+}
+
+// ListAllAssets returns the assets of all projects of the user.
+func (c *Client) ListAllAssets() ([]Asset, error) {
+	assets := make([]Asset, 0)
+	err := c.call(c.APIPath("/assets"), "GET", nil, http.StatusOK, &assets)
+	return assets, err
+}
+
+// UploadAsset creates a new asset in a project. If an asset of that name
+// already exists, the existing asset is returned together with an error
+// for which IsConflict is true.
+func (c *Client) UploadAsset(projectID int, name, mimeType string, data []byte) (*Asset, error) {
+	a := &Asset{}
+	param := &assetParam{assetPost{Name: name, Asset: DataURI(mimeType, data)}}
+	err := c.call(c.APIPath(AssetsPath(projectID)), "POST", param, http.StatusCreated, a)
+	if IsConflict(err) {
+		// the server answers a duplicate with the existing asset
+		if json.Unmarshal(err.(*Error).Body, a) != nil {
+			return nil, err
+		}
+		return a, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// UpdateAsset replaces the content of an existing asset.
+func (c *Client) UpdateAsset(projectID, assetID int, name, mimeType string, data []byte) error {
+	param := &assetParam{assetPost{Name: name, Asset: DataURI(mimeType, data)}}
+	return c.call(c.APIPath(AssetPath(projectID, assetID)), "PUT", param, http.StatusNoContent, nil)
+}
+
+// DownloadAsset writes the content of the named asset to w.
+func (c *Client) DownloadAsset(projectID int, name string, w io.Writer) error {
+	r, err := c.OpenAsset(projectID, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// OpenAsset returns the content of the named asset to be read as it is
+// received. The caller must close it.
+func (c *Client) OpenAsset(projectID int, name string) (io.ReadCloser, error) {
+	if !c.Auth.Valid() {
+		return nil, ErrNotLoggedIn
+	}
+	resp, err := c.Do(c.APIPath(AssetstorePath(projectID)), "GET", &assetNameString{name})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, newError(resp.StatusCode, http.StatusOK, body)
+	}
+	return resp.Body, nil
+}
+
+// DeleteAsset removes an asset from a project.
+func (c *Client) DeleteAsset(a *Asset) error {
+	return c.call(c.APIPath(AssetPath(a.ProjectId, a.ID)), "DELETE", &assetNameString{a.Name}, http.StatusNoContent, nil)
+}
@@ -0,0 +1,243 @@
+//
+//   Copyright 2016, 2017 Digital Incubation and Growth GmbH
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+//
+
+// Package client is a Go client for the slyft.io API. It is used by the
+// slyft command line tool and can be imported by other Go programs that
+// want to drive Slyft without shelling out to the CLI.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the production backend.
+const DefaultBaseURL = "https://api.slyft.io/"
+
+// Auth holds the devise_token_auth credentials returned by the server
+// on login. All three values are required for authenticated requests.
+type Auth struct {
+	AccessToken string `json:"access_token"`
+	Client      string `json:"client"`
+	Uid         string `json:"uid"`
+	Expiry      string `json:"expiry,omitempty"`
+}
+
+// Valid reports whether all credential fields are set.
+func (a *Auth) Valid() bool {
+	return a != nil && a.AccessToken != "" && a.Client != "" && a.Uid != ""
+}
+
+// ExpiresAfter reports whether a is valid longer than other. Tokens
+// without expiry (e.g. stored by older versions) never expire after
+// any other.
+func (a Auth) ExpiresAfter(other *Auth) bool {
+	mine, err := strconv.ParseInt(a.Expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	theirs, err := strconv.ParseInt(other.Expiry, 10, 64)
+	return err != nil || mine > theirs
+}
+
+// AuthFromHeader extracts credentials from response headers.
+func AuthFromHeader(hdr http.Header) Auth {
+	return Auth{
+		AccessToken: hdr.Get("access-token"),
+		Client:      hdr.Get("client"),
+		Uid:         hdr.Get("uid"),
+		Expiry:      hdr.Get("expiry"),
+	}
+}
+
+// Client talks to a Slyft backend.
+type Client struct {
+	BaseURL    string
+	Auth       *Auth
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+	APIVersion int
+
+	// Logf, if set, receives debug output on requests and retries.
+	Logf func(format string, args ...interface{})
+
+	// OnAuthUpdate, if set, is called when the server rotated the access
+	// token, so the new one can be persisted. c.Auth is already updated.
+	OnAuthUpdate func(auth *Auth)
+}
+
+// New returns a client for the given backend. An empty baseURL selects
+// DefaultBaseURL, auth may be nil for unauthenticated calls.
+func New(baseURL string, auth *Auth) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:    baseURL,
+		Auth:       auth,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		Retry:      DefaultRetryPolicy,
+		APIVersion: DefaultAPIVersion,
+	}
+}
+
+// URL returns the absolute URL of resource on the backend.
+func (c *Client) URL(resource string) string {
+	return strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(resource, "/")
+}
+
+// NewRequest creates a request for resource with params encoded as a
+// JSON body. Auth headers are added if the client has credentials.
+func (c *Client) NewRequest(resource, method string, params interface{}) (*http.Request, error) {
+	b := new(bytes.Buffer)
+	if params != nil {
+		if err := json.NewEncoder(b).Encode(params); err != nil {
+			return nil, err
+		}
+	}
+	return c.NewRawRequest(resource, method, "application/json; charset=utf-8", b.Bytes())
+}
+
+// NewRawRequest creates a request for resource with body sent as is.
+func (c *Client) NewRawRequest(resource, method, contentType string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.URL(resource), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.Auth != nil {
+		req.Header.Add("access-token", c.Auth.AccessToken)
+		req.Header.Add("client", c.Auth.Client)
+		req.Header.Add("uid", c.Auth.Uid)
+	}
+	req.Header.Add("Content-Type", contentType)
+	return req, nil
+}
+
+// Do sends a request and returns the raw response, retrying transient
+// failures according to c.Retry. The caller must close the response body.
+func (c *Client) Do(resource, method string, params interface{}) (*http.Response, error) {
+	return c.send(resource, method, func() (*http.Request, error) {
+		return c.NewRequest(resource, method, params)
+	})
+}
+
+// send is Do for requests built by newRequest.
+func (c *Client) send(resource, method string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		// the body is consumed by each attempt, so build a fresh request
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		c.logf("req=%#v", req)
+
+		resp, err := c.httpClient().Do(req)
+		c.logf("resp=%#v", resp)
+		if err == nil {
+			c.updateAuth(resp)
+		}
+
+		if attempt >= c.Retry.Attempts || !shouldRetry(method, resp, err) {
+			return resp, err
+		}
+
+		wait := c.Retry.backoff(attempt, resp)
+		if err != nil {
+			c.logf("%s %s failed: %v, retrying in %v", method, resource, err, wait)
+		} else {
+			c.logf("%s %s returned %d, retrying in %v", method, resource, resp.StatusCode, wait)
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		time.Sleep(wait)
+	}
+}
+
+// updateAuth takes over a rotated access token from the response. The
+// server sends no (or the same) token for requests within a batch, in
+// which case the current token stays valid.
+func (c *Client) updateAuth(resp *http.Response) {
+	if c.Auth == nil {
+		return
+	}
+	next := AuthFromHeader(resp.Header)
+	if next.AccessToken == "" || next.AccessToken == c.Auth.AccessToken {
+		return
+	}
+	if next.Client == "" {
+		next.Client = c.Auth.Client
+	}
+	if next.Uid == "" {
+		next.Uid = c.Auth.Uid
+	}
+	c.logf("access token rotated by server")
+	*c.Auth = next
+	if c.OnAuthUpdate != nil {
+		c.OnAuthUpdate(&next)
+	}
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// call sends a request, checks the status code against expected and
+// decodes the response body into v (if v is not nil).
+func (c *Client) call(resource, method string, params interface{}, expected int, v interface{}) error {
+	if !c.Auth.Valid() {
+		return ErrNotLoggedIn
+	}
+	resp, err := c.Do(resource, method, params)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, expected, v)
+}
+
+// decodeResponse checks the status code of resp against expected and
+// decodes the body into v (if v is not nil). It closes the body.
+func decodeResponse(resp *http.Response, expected int, v interface{}) error {
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != expected {
+		return newError(resp.StatusCode, expected, body)
+	}
+
+	if v == nil || len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
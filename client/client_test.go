@@ -0,0 +1,258 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testAuth = &Auth{AccessToken: "token", Client: "client", Uid: "foo@bar.boo"}
+
+func TestURL(t *testing.T) {
+	c := New("http://localhost:3000/", nil)
+	if u := c.URL("/v1/projects"); u != "http://localhost:3000/v1/projects" {
+		t.Errorf("Unexpected URL %s", u)
+	}
+	c = New("", nil)
+	if u := c.URL("auth"); u != DefaultBaseURL+"auth" {
+		t.Errorf("Unexpected URL %s", u)
+	}
+}
+
+func TestAuthHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := AuthFromHeader(r.Header); got != *testAuth {
+			t.Errorf("Auth headers not sent, got %#v", got)
+		}
+		w.Write([]byte(`[{"id": 1, "name": "p1"}, {"id": 2, "name": "p2"}]`))
+	}))
+	defer ts.Close()
+
+	projects, err := New(ts.URL, testAuth).ListProjects()
+	if err != nil {
+		t.Fatalf("ListProjects failed: %v", err)
+	}
+	if len(projects) != 2 || projects[1].Name != "p2" {
+		t.Errorf("Unexpected projects %#v", projects)
+	}
+}
+
+func TestNotLoggedIn(t *testing.T) {
+	if _, err := New("http://localhost:0", nil).ListProjects(); err != ErrNotLoggedIn {
+		t.Errorf("Expected ErrNotLoggedIn, got %v", err)
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors": ["Project not found"]}`))
+	}))
+	defer ts.Close()
+
+	_, err := New(ts.URL, testAuth).GetProject(42)
+	if !IsNotFound(err) {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+	e := err.(*Error)
+	if len(e.Messages) != 1 || e.Messages[0] != "Project not found" {
+		t.Errorf("Unexpected messages %#v", e.Messages)
+	}
+}
+
+func TestUploadAssetConflict(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var param assetParam
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &param); err != nil {
+			t.Fatalf("Invalid request body: %v", err)
+		}
+		if r.URL.Path != "/v1/projects/7/assets" || param.Asset.Name != "api.raml" {
+			t.Errorf("Unexpected request %s %#v", r.URL.Path, param)
+		}
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"id": 3, "name": "api.raml", "project_id": 7}`))
+	}))
+	defer ts.Close()
+
+	a, err := New(ts.URL, testAuth).UploadAsset(7, "api.raml", "application/x-yaml", []byte("#%RAML 1.0"))
+	if !IsConflict(err) {
+		t.Fatalf("Expected conflict, got %v", err)
+	}
+	if a == nil || a.ID != 3 {
+		t.Errorf("Expected existing asset, got %#v", a)
+	}
+}
+
+func TestDownloadAsset(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	var b bytes.Buffer
+	if err := New(ts.URL, testAuth).DownloadAsset(7, "api.raml", &b); err != nil {
+		t.Fatalf("DownloadAsset failed: %v", err)
+	}
+	if b.String() != "content" {
+		t.Errorf("Unexpected content %q", b.String())
+	}
+}
+
+func TestAuthRotation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("access-token") == "token" {
+			w.Header().Set("access-token", "rotated")
+			w.Header().Set("client", "client")
+			w.Header().Set("uid", "foo@bar.boo")
+			w.Header().Set("expiry", "1500000000")
+		}
+		// no token headers for the second request, as in a batch
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+
+	auth := *testAuth
+	var updated *Auth
+	c := New(ts.URL, &auth)
+	c.OnAuthUpdate = func(a *Auth) { updated = a }
+
+	if _, err := c.ListProjects(); err != nil {
+		t.Fatalf("ListProjects failed: %v", err)
+	}
+	if updated == nil || updated.AccessToken != "rotated" || c.Auth.AccessToken != "rotated" {
+		t.Fatalf("Rotated token must be taken over, got %#v", c.Auth)
+	}
+
+	updated = nil
+	if _, err := c.ListProjects(); err != nil {
+		t.Fatalf("ListProjects failed: %v", err)
+	}
+	if updated != nil || c.Auth.AccessToken != "rotated" {
+		t.Errorf("Token must be kept if the server sends none, got %#v", c.Auth)
+	}
+}
+
+func TestExpiresAfter(t *testing.T) {
+	older := &Auth{Expiry: "1500000000"}
+	newer := Auth{Expiry: "1600000000"}
+	if !newer.ExpiresAfter(older) || older.ExpiresAfter(&newer) {
+		t.Error("Must compare expiry timestamps")
+	}
+	if (Auth{}).ExpiresAfter(older) {
+		t.Error("Token without expiry must not expire after others")
+	}
+}
+
+func TestUploadWithoutProgress(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"id": "u1", "offset": 0}`))
+	}))
+	defer ts.Close()
+
+	u := &Upload{ID: "u1", Name: "big.json", Size: 10, ChunkSize: 4}
+	_, err := New(ts.URL, testAuth).UploadFrom(1, u, bytes.NewReader(make([]byte, 10)), nil)
+	if err == nil {
+		t.Fatal("Expected an error for an upload that makes no progress")
+	}
+	if requests != 1 {
+		t.Errorf("Expected to give up after 1 request, got %d", requests)
+	}
+}
+
+// TestCreateProjectParam checks the project parameter sent to create a
+// project and to update its settings.
+func TestCreateProjectParam(t *testing.T) {
+	now := time.Now()
+	var param projectParam
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		param = projectParam{}
+		if err := json.NewDecoder(r.Body).Decode(&param); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		if r.Method == "PUT" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 1, "name": "TestName", "details": "TestDetails"}`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, testAuth)
+	p, err := c.CreateProject("TestName", "TestDetails")
+	if err != nil || p.ID != 1 {
+		t.Errorf("Unexpected project %#v (%v)", p, err)
+	}
+	if param.Project.Name != "TestName" ||
+		param.Project.Details != "TestDetails" ||
+		param.Project.Settings != "" ||
+		param.Project.CreatedAt.After(now) ||
+		param.Project.UpdatedAt.After(now) {
+		t.Errorf("Broken project parameter: %#v", param)
+	}
+
+	if err := c.UpdateProjectSettings(1, "TestSettings"); err != nil {
+		t.Fatalf("UpdateProjectSettings failed: %v", err)
+	}
+	if param.Project.Settings != "TestSettings" || param.Project.Name != "" || param.Project.Details != "" {
+		t.Errorf("Broken project parameter: %#v", param)
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var creds Credentials
+		json.NewDecoder(r.Body).Decode(&creds)
+		if creds.Password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errors": ["Invalid login credentials. Please try again."]}`))
+			return
+		}
+		w.Header().Set("access-token", testAuth.AccessToken)
+		w.Header().Set("client", testAuth.Client)
+		w.Header().Set("uid", testAuth.Uid)
+	}))
+	defer ts.Close()
+
+	auth, err := New(ts.URL, nil).SignIn(&Credentials{Email: testAuth.Uid, Password: "secret"})
+	if err != nil || *auth != *testAuth {
+		t.Errorf("Expected the credentials of the headers, got %#v (%v)", auth, err)
+	}
+	_, err = New(ts.URL, nil).SignIn(&Credentials{Email: testAuth.Uid, Password: "wrong"})
+	if !IsUnauthorized(err) || len(err.(*Error).Messages) != 1 {
+		t.Errorf("Expected the messages of the server, got %v", err)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/projects/7/jobs/4/cancel" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id": 4, "status": "cancelled"}`))
+	}))
+	defer ts.Close()
+
+	j, err := New(ts.URL, testAuth).CancelJob(7, 4)
+	if err != nil || j.Status != "cancelled" {
+		t.Errorf("Expected the cancelled job, got %#v (%v)", j, err)
+	}
+	for _, status = range []int{http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented} {
+		if _, err := New(ts.URL, testAuth).CancelJob(7, 4); err != ErrCancelUnsupported {
+			t.Errorf("Expected ErrCancelUnsupported for status %d, got %v", status, err)
+		}
+	}
+	status = http.StatusConflict
+	if _, err := New(ts.URL, testAuth).CancelJob(7, 4); !IsConflict(err) {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+}
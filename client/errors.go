@@ -0,0 +1,85 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrNotLoggedIn is returned when a call needs credentials but the
+// client has none.
+var ErrNotLoggedIn = errors.New("Not logged in.")
+
+// Error is returned when the server answers with an unexpected status
+// code. Messages holds the error messages from the body, if any.
+type Error struct {
+	StatusCode int
+	Expected   int
+	Messages   []string
+	Body       []byte
+}
+
+func newError(status, expected int, body []byte) *Error {
+	return &Error{
+		StatusCode: status,
+		Expected:   expected,
+		Messages:   errorMessages(body),
+		Body:       body,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == http.StatusUnauthorized {
+		return "Unauthorized, please log in first."
+	}
+	msg := fmt.Sprintf("Unexpected return code from API, was=%d, expected=%d", e.StatusCode, e.Expected)
+	if len(e.Messages) > 0 {
+		msg += ": " + strings.Join(e.Messages, ", ")
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is a 404 from the server.
+func IsNotFound(err error) bool {
+	return statusOf(err) == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 from the server, e.g. when
+// creating an asset that already exists.
+func IsConflict(err error) bool {
+	return statusOf(err) == http.StatusConflict
+}
+
+// IsUnauthorized reports whether err is a 401 from the server.
+func IsUnauthorized(err error) bool {
+	return statusOf(err) == http.StatusUnauthorized
+}
+
+func statusOf(err error) int {
+	if e, ok := err.(*Error); ok {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// errorMessages extracts the messages of the two error formats used by
+// the server: {"errors": ["..."]} and {"errors": {"full_messages": [...]}}.
+func errorMessages(body []byte) []string {
+	var plain struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &plain); err == nil {
+		return plain.Errors
+	}
+	var full struct {
+		Errors struct {
+			FullMessages []string `json:"full_messages"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &full); err == nil {
+		return full.Errors.FullMessages
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type Job struct {
+	ID          int        `json:"id"`
+	Kind        string     `json:"kind"`
+	Status      string     `json:"status"`
+	Results     JobResults `json:"results"`
+	ProjectId   int        `json:"project_id"`
+	ProjectName string     `json:"project_name"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+type JobResults struct {
+	ResultMessage string   `json:"resultMessage"`
+	ResultStatus  int      `json:"resultStatus"`
+	ResultAssets  []string `json:"resultAssets"`
+	ResultDetails []string `json:"resultDetails"`
+}
+
+// ErrCancelUnsupported is returned by CancelJob if the server does not
+// offer cancelling jobs.
+var ErrCancelUnsupported = errors.New("the server does not support cancelling jobs")
+
+// JobKind is a kind of job the server runs.
+type JobKind struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type jobParam struct {
+	Job Job `json:"job"`
+}
+
+func JobsPath(projectID int) string {
+	return ProjectPath(projectID) + "/jobs"
+}
+
+func JobPath(projectID, jobID int) string {
+	return fmt.Sprintf("%s/%d", JobsPath(projectID), jobID)
+}
+
+// ListJobs returns the jobs of a project.
+func (c *Client) ListJobs(projectID int) ([]Job, error) {
+	jobs := make([]Job, 0)
+	err := c.call(c.APIPath(JobsPath(projectID)), "GET", nil, http.StatusOK, &jobs)
+	return jobs, err
+}
+
+// GetJob fetches a single job.
+func (c *Client) GetJob(projectID, jobID int) (*Job, error) {
+	j := &Job{}
+	if err := c.call(c.APIPath(JobPath(projectID, jobID)), "GET", nil, http.StatusOK, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// CreateJob starts a job of the given kind (e.g. "build", "validate")
+// for a project.
+func (c *Client) CreateJob(projectID int, kind string) (*Job, error) {
+	return c.RunJob(projectID, kind, nil)
+}
+
+// RunJob starts a job of any kind the server runs, with its parameters
+// (may be nil).
+func (c *Client) RunJob(projectID int, kind string, params map[string]interface{}) (*Job, error) {
+	j := &Job{}
+	param := &jobParam{Job{Kind: kind, ProjectId: projectID, Params: params}}
+	if err := c.call(c.APIPath(JobsPath(projectID)), "POST", param, http.StatusCreated, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// ListJobKinds returns the kinds of jobs the server runs. Servers which
+// don't list them answer with a 404 *Error.
+func (c *Client) ListJobKinds() ([]JobKind, error) {
+	kinds := make([]JobKind, 0)
+	err := c.call(c.APIPath("/job_kinds"), "GET", nil, http.StatusOK, &kinds)
+	return kinds, err
+}
+
+// CancelJob cancels a queued or running job and returns it. Servers which
+// can't cancel jobs make it fail with ErrCancelUnsupported.
+func (c *Client) CancelJob(projectID, jobID int) (*Job, error) {
+	j := &Job{}
+	err := c.call(c.APIPath(JobPath(projectID, jobID)+"/cancel"), "POST", nil, http.StatusOK, j)
+	switch statusOf(err) {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrCancelUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
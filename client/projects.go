@@ -0,0 +1,81 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Project struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Details   string    `json:"details"`
+	Settings  string    `json:"settings"`
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type projectParam struct {
+	Project Project `json:"project"`
+}
+
+type searchString struct {
+	SearchString string `json:"search_string"`
+}
+
+// ProjectPath and the other path helpers return paths relative to the
+// API version prefix, see Client.APIPath.
+func ProjectPath(id int) string {
+	return fmt.Sprintf("/projects/%d", id)
+}
+
+// ListProjects returns all projects of the logged in user.
+func (c *Client) ListProjects() ([]Project, error) {
+	projects := make([]Project, 0)
+	err := c.call(c.APIPath("/projects"), "GET", nil, http.StatusOK, &projects)
+	return projects, err
+}
+
+// SearchProjects returns the projects whose name contains portion. An
+// empty portion lists all projects.
+func (c *Client) SearchProjects(portion string) ([]Project, error) {
+	if strings.TrimSpace(portion) == "" {
+		return c.ListProjects()
+	}
+	projects := make([]Project, 0)
+	err := c.call(c.APIPath("/projects/search"), "GET", &searchString{portion}, http.StatusOK, &projects)
+	return projects, err
+}
+
+// GetProject fetches a single project.
+func (c *Client) GetProject(id int) (*Project, error) {
+	p := &Project{}
+	if err := c.call(c.APIPath(ProjectPath(id)), "GET", nil, http.StatusOK, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CreateProject creates a new project.
+func (c *Client) CreateProject(name, details string) (*Project, error) {
+	p := &Project{}
+	param := &projectParam{Project{Name: name, Details: details}}
+	if err := c.call(c.APIPath("/projects"), "POST", param, http.StatusCreated, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// UpdateProjectSettings replaces the settings of a project. settings is
+// a JSON document serialized as string.
+func (c *Client) UpdateProjectSettings(id int, settings string) error {
+	param := &projectParam{Project{Settings: settings}}
+	return c.call(c.APIPath(ProjectPath(id)), "PUT", param, http.StatusNoContent, nil)
+}
+
+// DeleteProject deletes a project including its assets and jobs.
+func (c *Client) DeleteProject(id int) error {
+	return c.call(c.APIPath(ProjectPath(id)), "DELETE", nil, http.StatusNoContent, nil)
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. Idempotent
+// requests (GET, PUT, DELETE) are retried on network errors and on
+// 429/502/503/504 responses. POST requests are only retried if the
+// request never reached the server or the server answered 429 or 503,
+// i.e. confirmed that nothing was processed.
+type RetryPolicy struct {
+	// Attempts is the total number of attempts, values below 2 disable retries.
+	Attempts int
+	// MinBackoff is the wait before the first retry, doubling for each further retry.
+	MinBackoff time.Duration
+	// MaxBackoff caps the wait between two attempts (also for Retry-After).
+	MaxBackoff time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+}
+
+// NoRetry disables retries.
+var NoRetry = RetryPolicy{Attempts: 1}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// shouldRetry decides whether a request with the given method may be
+// sent again after it resulted in resp/err.
+func shouldRetry(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		if isIdempotent(method) {
+			return true
+		}
+		// a failed dial means the request has not been sent at all
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		opErr, ok := err.(*net.OpError)
+		return ok && opErr.Op == "dial"
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	}
+	return false
+}
+
+// backoff returns the wait before retry number n (starting at 1), with
+// jitter. A Retry-After header in resp takes precedence.
+func (p RetryPolicy) backoff(n int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if p.MaxBackoff > 0 && d > p.MaxBackoff {
+				return p.MaxBackoff
+			}
+			return d
+		}
+	}
+
+	d := p.MinBackoff << uint(n-1)
+	if p.MaxBackoff > 0 && (d > p.MaxBackoff || d <= 0) {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// wait between half and the full backoff
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter parses a Retry-After value, given either in seconds or as
+// HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := t.Sub(time.Now())
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func flakyServer(failures int, status int) (*httptest.Server, *int) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("[]"))
+	}))
+	return ts, &calls
+}
+
+func testClient(url string) *Client {
+	c := New(url, testAuth)
+	c.Retry = RetryPolicy{Attempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	return c
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	ts, calls := flakyServer(2, http.StatusBadGateway)
+	defer ts.Close()
+
+	if _, err := testClient(ts.URL).ListProjects(); err != nil {
+		t.Errorf("GET must be retried, got %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", *calls)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	ts, calls := flakyServer(5, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	if _, err := testClient(ts.URL).ListProjects(); statusOf(err) != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 after last attempt, got %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", *calls)
+	}
+}
+
+func TestNoRetryForPost(t *testing.T) {
+	ts, calls := flakyServer(1, http.StatusBadGateway)
+	defer ts.Close()
+
+	if _, err := testClient(ts.URL).CreateJob(1, "build"); statusOf(err) != http.StatusBadGateway {
+		t.Errorf("POST must not be retried on 502, got %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("Expected 1 call, got %d", *calls)
+	}
+}
+
+func TestRetryPostOnServiceUnavailable(t *testing.T) {
+	ts, calls := flakyServer(1, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	// the flaky server answers 200 instead of 201 after the failure
+	if _, err := testClient(ts.URL).CreateJob(1, "build"); statusOf(err) != http.StatusOK {
+		t.Errorf("POST must be retried on 503, got %v", err)
+	}
+	if *calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", *calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := retryAfter("3"); !ok || d != 3*time.Second {
+		t.Errorf("Must parse seconds, got %v", d)
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Error("Must reject invalid Retry-After")
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d, ok := retryAfter(date); !ok || d <= 0 || d > time.Minute {
+		t.Errorf("Must parse HTTP date, got %v", d)
+	}
+
+	p := RetryPolicy{Attempts: 3, MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"60"}}}
+	if d := p.backoff(1, resp); d != 10*time.Second {
+		t.Errorf("Retry-After must be capped by MaxBackoff, got %v", d)
+	}
+	if d := p.backoff(2, nil); d < time.Second || d > 2*time.Second {
+		t.Errorf("Unexpected backoff %v", d)
+	}
+}
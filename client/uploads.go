@@ -0,0 +1,161 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultChunkSize is used if the server does not ask for another size.
+const DefaultChunkSize = 1 << 20
+
+// ErrUploadsUnsupported is returned by CreateUpload if the server does
+// not offer chunked uploads. Assets then have to be uploaded as data URI.
+var ErrUploadsUnsupported = errors.New("the server does not support chunked uploads")
+
+// Upload is a chunked upload of an asset too large to be sent as data
+// URI. Chunks are sent in order; Offset tells how many bytes the server
+// has received, so an interrupted upload can be resumed from there.
+type Upload struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	MimeType  string `json:"mime_type"`
+	Size      int64  `json:"size"`
+	Digest    string `json:"sha256"`
+	AssetID   int    `json:"asset_id,omitempty"`
+	ChunkSize int64  `json:"chunk_size,omitempty"`
+	Offset    int64  `json:"offset"`
+}
+
+type uploadParam struct {
+	Upload *Upload `json:"upload"`
+}
+
+func UploadsPath(projectID int) string {
+	return ProjectPath(projectID) + "/uploads"
+}
+
+func UploadPath(projectID int, id string) string {
+	return fmt.Sprintf("%s/%s", UploadsPath(projectID), id)
+}
+
+// CreateUpload starts a chunked upload of size bytes with the given
+// SHA-256 digest (hex encoded). If assetID is not 0, the upload replaces
+// the content of that asset, otherwise a new asset is created.
+func (c *Client) CreateUpload(projectID int, name, mimeType string, size int64, digest string, assetID int) (*Upload, error) {
+	u := &Upload{Name: name, MimeType: mimeType, Size: size, Digest: digest, AssetID: assetID}
+	err := c.call(c.APIPath(UploadsPath(projectID)), "POST", &uploadParam{u}, http.StatusCreated, u)
+	switch statusOf(err) {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrUploadsUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.ChunkSize <= 0 {
+		u.ChunkSize = DefaultChunkSize
+	}
+	return u, nil
+}
+
+// GetUpload returns an upload with the offset the server has reached.
+func (c *Client) GetUpload(projectID int, id string) (*Upload, error) {
+	u := &Upload{}
+	if err := c.call(c.APIPath(UploadPath(projectID, id)), "GET", nil, http.StatusOK, u); err != nil {
+		return nil, err
+	}
+	if u.ChunkSize <= 0 {
+		u.ChunkSize = DefaultChunkSize
+	}
+	return u, nil
+}
+
+// UploadChunk sends the bytes of u at u.Offset and advances u.Offset to
+// what the server has received.
+func (c *Client) UploadChunk(projectID int, u *Upload, chunk []byte) error {
+	if !c.Auth.Valid() {
+		return ErrNotLoggedIn
+	}
+	resource := c.APIPath(UploadPath(projectID, u.ID))
+	offset := u.Offset
+	resp, err := c.send(resource, "PUT", func() (*http.Request, error) {
+		req, err := c.NewRawRequest(resource, "PUT", "application/octet-stream", chunk)
+		if err == nil {
+			req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(chunk))-1, u.Size))
+		}
+		return req, err
+	})
+	if err != nil {
+		return err
+	}
+	var received Upload
+	if err := decodeResponse(resp, http.StatusOK, &received); err != nil {
+		return err
+	}
+	u.Offset = received.Offset
+	return nil
+}
+
+// CompleteUpload finishes an upload once all bytes were sent and returns
+// the asset. Like UploadAsset, it returns the existing asset together
+// with a conflict error if a new asset's name is already taken.
+func (c *Client) CompleteUpload(projectID int, u *Upload) (*Asset, error) {
+	expected := http.StatusCreated
+	if u.AssetID != 0 {
+		expected = http.StatusOK
+	}
+	a := &Asset{}
+	err := c.call(c.APIPath(UploadPath(projectID, u.ID)+"/complete"), "POST", nil, expected, a)
+	if IsConflict(err) {
+		if json.Unmarshal(err.(*Error).Body, a) != nil {
+			return nil, err
+		}
+		return a, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// UploadFrom sends the remaining chunks of u, read from r, and completes
+// the upload. r is read from u.Offset on, so a resumed upload only reads
+// what the server is missing. progress, if set, is called after each
+// chunk. An error is returned if the server does not take a chunk.
+func (c *Client) UploadFrom(projectID int, u *Upload, r io.ReadSeeker, progress func(u *Upload)) (*Asset, error) {
+	if _, err := r.Seek(u.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	chunk := make([]byte, u.ChunkSize)
+	for u.Offset < u.Size {
+		offset := u.Offset
+		n, err := io.ReadFull(r, chunk[:min64(u.ChunkSize, u.Size-offset)])
+		if err != nil {
+			return nil, err
+		}
+		if err := c.UploadChunk(projectID, u, chunk[:n]); err != nil {
+			return nil, err
+		}
+		if u.Offset <= offset {
+			return nil, fmt.Errorf("the server did not take the bytes %d-%d of %s", offset, offset+int64(n)-1, u.Name)
+		}
+		if u.Offset != offset+int64(n) {
+			if _, err := r.Seek(u.Offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		if progress != nil {
+			progress(u)
+		}
+	}
+	return c.CompleteUpload(projectID, u)
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// Credentials sign a user in, or up together with the acceptance of the
+// terms and conditions.
+type Credentials struct {
+	Email                string          `json:"email"`
+	Password             string          `json:"password"`
+	PasswordConfirmation string          `json:"password_confirmation"`
+	TermsAcceptance      TermsAcceptance `json:"terms"`
+}
+
+type TermsAcceptance struct {
+	Accepted  bool   `json:"accepted"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Terms tell where to find the current terms and conditions.
+type Terms struct {
+	Url       string `json:"url"`
+	StartedAt string `json:"started_at"`
+}
+
+// PasswordReset sets a new password. Code is the reset code sent by
+// mail, it is not needed to change the password of a signed in user.
+type PasswordReset struct {
+	Code                 string `json:"code,omitempty"`
+	Password             string `json:"password"`
+	PasswordConfirmation string `json:"password_confirmation"`
+}
+
+type passwordResetRequest struct {
+	Email       string `json:"email"`
+	RedirectUrl string `json:"redirect_url"`
+}
+
+// GetTerms returns the current terms and conditions. It needs no
+// credentials.
+func (c *Client) GetTerms() (*Terms, error) {
+	t := &Terms{}
+	if _, err := c.authCall("/terms", "GET", nil, t, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Register signs a new user up and returns the credentials.
+func (c *Client) Register(creds *Credentials) (*Auth, error) {
+	return c.authCall("/auth", "POST", creds, nil, http.StatusOK, http.StatusCreated)
+}
+
+// SignIn returns the credentials of a user.
+func (c *Client) SignIn(creds *Credentials) (*Auth, error) {
+	return c.authCall("/auth/sign_in", "POST", creds, nil, http.StatusOK)
+}
+
+// SignOut invalidates the credentials of the client.
+func (c *Client) SignOut() error {
+	if !c.Auth.Valid() {
+		return ErrNotLoggedIn
+	}
+	_, err := c.authCall("/auth/sign_out", "DELETE", nil, nil, http.StatusOK, http.StatusNoContent)
+	return err
+}
+
+// DeleteAccount deletes the user of the client with all its data.
+func (c *Client) DeleteAccount() error {
+	if !c.Auth.Valid() {
+		return ErrNotLoggedIn
+	}
+	_, err := c.authCall("/auth", "DELETE", nil, nil, http.StatusOK, http.StatusNoContent)
+	return err
+}
+
+// RequestPasswordReset has the server mail a reset code to email, see
+// ResetPassword.
+func (c *Client) RequestPasswordReset(email string) error {
+	_, err := c.authCall("/auth/password", "POST", &passwordResetRequest{Email: email}, nil, http.StatusOK)
+	return err
+}
+
+// ResetPassword sets a new password with the mailed reset code.
+func (c *Client) ResetPassword(reset *PasswordReset) error {
+	_, err := c.authCall("/password_reset", "POST", reset, nil, http.StatusOK)
+	return err
+}
+
+// ChangePassword sets a new password for the user of the client.
+func (c *Client) ChangePassword(reset *PasswordReset) error {
+	if !c.Auth.Valid() {
+		return ErrNotLoggedIn
+	}
+	_, err := c.authCall("/auth/password", "PUT", reset, nil, http.StatusOK, http.StatusNoContent)
+	return err
+}
+
+// authCall is call for the auth endpoints, which are not versioned and
+// answer with one of several status codes. It returns the credentials
+// sent in the response headers.
+func (c *Client) authCall(resource, method string, params, v interface{}, expected ...int) (*Auth, error) {
+	resp, err := c.Do(resource, method, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range expected {
+		if resp.StatusCode != code {
+			continue
+		}
+		if v != nil && len(body) > 0 {
+			if err := json.Unmarshal(body, v); err != nil {
+				return nil, err
+			}
+		}
+		auth := AuthFromHeader(resp.Header)
+		return &auth, nil
+	}
+	return nil, newError(resp.StatusCode, expected[0], body)
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// API versions supported by this client.
+const (
+	MinAPIVersion     = 1
+	MaxAPIVersion     = 1
+	DefaultAPIVersion = 1
+)
+
+// NegotiateVersion returns the highest API version supported by both
+// this client and a server supporting serverMin to serverMax. A server
+// not announcing versions (0, 0) is assumed to speak DefaultAPIVersion.
+func NegotiateVersion(serverMin, serverMax int) (int, error) {
+	if serverMin == 0 && serverMax == 0 {
+		return DefaultAPIVersion, nil
+	}
+	if serverMin == 0 {
+		serverMin = 1
+	}
+	if serverMax == 0 {
+		serverMax = serverMin
+	}
+
+	version := MaxAPIVersion
+	if serverMax < version {
+		version = serverMax
+	}
+	if version < MinAPIVersion || version < serverMin {
+		return 0, errors.New(fmt.Sprintf("No common API version, server supports %d to %d, client %d to %d",
+			serverMin, serverMax, MinAPIVersion, MaxAPIVersion))
+	}
+	return version, nil
+}
+
+// APIPath prefixes path with the API version of the client, e.g.
+// "/projects" becomes "/v1/projects".
+func (c *Client) APIPath(path string) string {
+	version := c.APIVersion
+	if version == 0 {
+		version = DefaultAPIVersion
+	}
+	return fmt.Sprintf("/v%d%s", version, path)
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	if v, err := NegotiateVersion(0, 0); err != nil || v != DefaultAPIVersion {
+		t.Errorf("Must default to %d, got %d (%v)", DefaultAPIVersion, v, err)
+	}
+	if v, err := NegotiateVersion(1, MaxAPIVersion+1); err != nil || v != MaxAPIVersion {
+		t.Errorf("Must pick the highest common version, got %d (%v)", v, err)
+	}
+	if _, err := NegotiateVersion(MaxAPIVersion+1, MaxAPIVersion+2); err == nil {
+		t.Error("Must fail without common version")
+	}
+}
+
+func TestAPIPath(t *testing.T) {
+	c := New("", nil)
+	if p := c.APIPath(ProjectPath(3)); p != "/v1/projects/3" {
+		t.Errorf("Unexpected path %s", p)
+	}
+	c.APIVersion = 2
+	if p := c.APIPath(JobsPath(3)); p != "/v2/projects/3/jobs" {
+		t.Errorf("Unexpected path %s", p)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// CredentialStore keeps the auth tokens of the profiles. Which store is
+// used is set by "credential_store" in ~/.slyftrc (default: file).
+type CredentialStore interface {
+	Load(profile string) (*SlyftAuth, error)
+	Save(profile string, auth *SlyftAuth) error
+	Delete(profile string) error
+}
+
+const defaultCredentialStore = "file"
+
+// credentialStores maps store names to constructors. Platform specific
+// stores register themselves in init().
+var credentialStores = map[string]func(sr *SlyftRC) CredentialStore{
+	"file":           func(sr *SlyftRC) CredentialStore { return &fileCredentialStore{sr} },
+	"encrypted-file": func(sr *SlyftRC) CredentialStore { return &encryptedFileCredentialStore{defaultCredentialsFile()} },
+}
+
+func credentialStore(sr *SlyftRC) (CredentialStore, error) {
+	name := sr.CredentialStore
+	if name == "" {
+		name = defaultCredentialStore
+	}
+	newStore, ok := credentialStores[name]
+	if !ok {
+		names := make([]string, 0, len(credentialStores))
+		for n := range credentialStores {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, errors.New(fmt.Sprintf("Unknown credential_store '%s' in %s, use one of %s",
+			name, defaultConfigFile(), strings.Join(names, ", ")))
+	}
+	return newStore(sr), nil
+}
+
+// fileCredentialStore keeps the tokens in ~/.slyftrc itself. Changes
+// are written with the config file.
+type fileCredentialStore struct {
+	sr *SlyftRC
+}
+
+func (s *fileCredentialStore) Load(profile string) (*SlyftAuth, error) {
+	auth := SlyftAuth{}
+	if p := s.sr.profile(profile); p.Auth != nil {
+		auth = *p.Auth
+	}
+	return &auth, nil
+}
+
+func (s *fileCredentialStore) Save(profile string, auth *SlyftAuth) error {
+	saved := *auth
+	s.sr.profile(profile).Auth = &saved
+	return nil
+}
+
+func (s *fileCredentialStore) Delete(profile string) error {
+	s.sr.profile(profile).Auth = nil
+	return nil
+}
+
+// hasPlaintextAuth reports whether tokens are kept in ~/.slyftrc while
+// another store is chosen, e.g. from before switching to it.
+func (sr *SlyftRC) hasPlaintextAuth() bool {
+	if sr.CredentialStore == "" || sr.CredentialStore == defaultCredentialStore {
+		return false
+	}
+	if sr.Auth != nil {
+		return true
+	}
+	for _, p := range sr.Profiles {
+		if p.Auth != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// removePlaintextAuth removes the tokens from ~/.slyftrc if another
+// store is chosen. Tokens of profiles without credentials in that store
+// are moved there, so nobody has to log in again. Tokens which can't be
+// moved stay in ~/.slyftrc until the store works.
+func (sr *SlyftRC) removePlaintextAuth() {
+	if !sr.hasPlaintextAuth() {
+		return
+	}
+	sr.profile(defaultProfileName)
+	store, err := credentialStore(sr)
+	if err != nil {
+		Log.Warningf("Unable to move the credentials to %s, keeping them in %s: %v",
+			sr.CredentialStore, defaultConfigFile(), err)
+		return
+	}
+	for name, p := range sr.Profiles {
+		if p.Auth == nil {
+			continue
+		}
+		if p.Auth.GoodForLogin() {
+			if stored, err := store.Load(name); err != nil || !stored.GoodForLogin() {
+				if err := store.Save(name, p.Auth); err != nil {
+					Log.Warningf("Unable to move the credentials of profile %s to %s, keeping them in %s: %v",
+						name, sr.CredentialStore, defaultConfigFile(), err)
+					continue
+				}
+			}
+		}
+		p.Auth = nil
+	}
+}
+
+func defaultCredentialsFile() string {
+	return filepath.FromSlash(portableGetUsersHome() + "/.slyft-credentials")
+}
+
+// encryptedFileCredentialStore keeps the tokens of all profiles in a
+// separate file, encrypted with AES-GCM. The key is derived from the
+// passphrase in SLYFT_CREDENTIALS_PASSPHRASE.
+type encryptedFileCredentialStore struct {
+	file string
+}
+
+type encryptedCredentials struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+func (s *encryptedFileCredentialStore) key(salt []byte) ([]byte, error) {
+	passphrase := os.Getenv("SLYFT_CREDENTIALS_PASSPHRASE")
+	if passphrase == "" {
+		return nil, errors.New("SLYFT_CREDENTIALS_PASSPHRASE must be set for the encrypted-file credential store")
+	}
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func (s *encryptedFileCredentialStore) read() (map[string]SlyftAuth, error) {
+	all := make(map[string]SlyftAuth)
+	content, err := readFile(s.file)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var enc encryptedCredentials
+	if err := json.Unmarshal(content, &enc); err != nil {
+		return nil, err
+	}
+	key, err := s.key(enc.Salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, enc.Nonce, enc.Data, nil)
+	if err != nil {
+		return nil, errors.New("Unable to decrypt " + s.file + ", wrong passphrase?")
+	}
+	if err := json.Unmarshal(plain, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func (s *encryptedFileCredentialStore) write(all map[string]SlyftAuth) error {
+	plain, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	enc := encryptedCredentials{
+		Salt:  make([]byte, 16),
+		Nonce: make([]byte, 12),
+	}
+	if _, err := io.ReadFull(rand.Reader, enc.Salt); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(rand.Reader, enc.Nonce); err != nil {
+		return err
+	}
+	key, err := s.key(enc.Salt)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	enc.Data = gcm.Seal(nil, enc.Nonce, plain, nil)
+
+	content, err := json.Marshal(&enc)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.file, content, 0600)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *encryptedFileCredentialStore) Load(profile string) (*SlyftAuth, error) {
+	all, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	auth := all[profile]
+	return &auth, nil
+}
+
+func (s *encryptedFileCredentialStore) Save(profile string, auth *SlyftAuth) error {
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	all[profile] = *auth
+	return s.write(all)
+}
+
+func (s *encryptedFileCredentialStore) Delete(profile string) error {
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := all[profile]; !ok {
+		return nil
+	}
+	delete(all, profile)
+	return s.write(all)
+}
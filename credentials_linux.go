@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	credentialStores["secret-service"] = func(sr *SlyftRC) CredentialStore { return &secretToolCredentialStore{} }
+	credentialStores["pass"] = func(sr *SlyftRC) CredentialStore { return &passCredentialStore{} }
+}
+
+// runSecretCommand runs an external secret store tool, feeding it input
+// on stdin, and returns its output.
+func runSecretCommand(input []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		Log.Debugf("%s %s: %s", name, strings.Join(args, " "), stderr.String())
+	}
+	return out, err
+}
+
+func parseSecret(out []byte) (*SlyftAuth, error) {
+	var auth SlyftAuth
+	if len(bytes.TrimSpace(out)) == 0 {
+		return &auth, nil
+	}
+	if err := json.Unmarshal(out, &auth); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// secretToolCredentialStore uses the freedesktop Secret Service (GNOME
+// Keyring, KWallet) through `secret-tool` from libsecret.
+type secretToolCredentialStore struct{}
+
+func (s *secretToolCredentialStore) Load(profile string) (*SlyftAuth, error) {
+	out, err := runSecretCommand(nil, "secret-tool", "lookup", "service", "slyft", "profile", profile)
+	if _, ok := err.(*exec.ExitError); ok {
+		// no such secret
+		return &SlyftAuth{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSecret(out)
+}
+
+func (s *secretToolCredentialStore) Save(profile string, auth *SlyftAuth) error {
+	secret, err := json.Marshal(auth)
+	if err != nil {
+		return err
+	}
+	_, err = runSecretCommand(secret, "secret-tool", "store", "--label=slyft ("+profile+")", "service", "slyft", "profile", profile)
+	return err
+}
+
+func (s *secretToolCredentialStore) Delete(profile string) error {
+	runSecretCommand(nil, "secret-tool", "clear", "service", "slyft", "profile", profile)
+	return nil
+}
+
+// passCredentialStore uses the standard unix password manager `pass`,
+// storing each profile as slyft/<profile>.
+type passCredentialStore struct{}
+
+func (s *passCredentialStore) Load(profile string) (*SlyftAuth, error) {
+	out, err := runSecretCommand(nil, "pass", "show", "slyft/"+profile)
+	if _, ok := err.(*exec.ExitError); ok {
+		// pass fails for unknown entries, treat as not logged in
+		return &SlyftAuth{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSecret(out)
+}
+
+func (s *passCredentialStore) Save(profile string, auth *SlyftAuth) error {
+	secret, err := json.Marshal(auth)
+	if err != nil {
+		return err
+	}
+	_, err = runSecretCommand(secret, "pass", "insert", "--multiline", "--force", "slyft/"+profile)
+	return err
+}
+
+func (s *passCredentialStore) Delete(profile string) error {
+	runSecretCommand(nil, "pass", "rm", "--force", "slyft/"+profile)
+	return nil
+}
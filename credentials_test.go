@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedFileCredentialStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slyft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("SLYFT_CREDENTIALS_PASSPHRASE", "secret")
+	defer os.Unsetenv("SLYFT_CREDENTIALS_PASSPHRASE")
+
+	store := &encryptedFileCredentialStore{filepath.Join(dir, "credentials")}
+	auth := &SlyftAuth{AccessToken: "token", Client: "client", Uid: "foo@bar.boo"}
+	if err := store.Save("staging", auth); err != nil {
+		t.Fatalf("Must save credentials: %v", err)
+	}
+
+	info, err := os.Stat(store.file)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Credentials file must be private, got %v (%v)", info.Mode(), err)
+	}
+
+	loaded, err := store.Load("staging")
+	if err != nil || *loaded != *auth {
+		t.Errorf("Must load saved credentials, got %#v (%v)", loaded, err)
+	}
+
+	os.Setenv("SLYFT_CREDENTIALS_PASSPHRASE", "wrong")
+	if _, err := store.Load("staging"); err == nil {
+		t.Error("Must not decrypt with wrong passphrase")
+	}
+	os.Setenv("SLYFT_CREDENTIALS_PASSPHRASE", "secret")
+
+	if err := store.Delete("staging"); err != nil {
+		t.Fatalf("Must delete credentials: %v", err)
+	}
+	if loaded, _ := store.Load("staging"); loaded.GoodForLogin() {
+		t.Error("Deleted credentials must be gone")
+	}
+}
+
+func TestUnknownCredentialStore(t *testing.T) {
+	if _, err := credentialStore(&SlyftRC{CredentialStore: "vault"}); err == nil {
+		t.Error("Must reject unknown credential store")
+	}
+	if _, err := credentialStore(&SlyftRC{}); err != nil {
+		t.Errorf("Must default to file store: %v", err)
+	}
+}
+
+func TestSwitchingStoreRemovesPlaintextAuth(t *testing.T) {
+	_, cleanup := tempHome(t)
+	defer cleanup()
+	os.Setenv("SLYFT_CREDENTIALS_PASSPHRASE", "secret")
+	defer os.Unsetenv("SLYFT_CREDENTIALS_PASSPHRASE")
+
+	auth := &SlyftAuth{AccessToken: "token", Client: "client", Uid: "foo@bar.boo"}
+	if err := writeAuthToConfig(auth); err != nil {
+		t.Fatal(err)
+	}
+	// switch the store by editing ~/.slyftrc
+	content, _ := ioutil.ReadFile(defaultConfigFile())
+	content = []byte(strings.Replace(string(content), "{", `{"credential_store": "encrypted-file",`, 1))
+	if err := ioutil.WriteFile(defaultConfigFile(), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := readAuthFromConfig()
+	if err != nil || *loaded != *auth {
+		t.Errorf("Must move the credentials to the new store, got %#v (%v)", loaded, err)
+	}
+	content, _ = ioutil.ReadFile(defaultConfigFile())
+	if strings.Contains(string(content), "token") {
+		t.Errorf("Must remove the plaintext token from the config, got %s", content)
+	}
+	if _, err := os.Stat(defaultCredentialsFile()); err != nil {
+		t.Errorf("Must write the encrypted credentials: %v", err)
+	}
+}
+
+func TestFailingStoreKeepsPlaintextAuth(t *testing.T) {
+	_, cleanup := tempHome(t)
+	defer cleanup()
+	os.Unsetenv("SLYFT_CREDENTIALS_PASSPHRASE")
+
+	auth := &SlyftAuth{AccessToken: "token", Client: "client", Uid: "foo@bar.boo"}
+	if err := writeAuthToConfig(auth); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadFile(defaultConfigFile())
+	content = []byte(strings.Replace(string(content), "{", `{"credential_store": "encrypted-file",`, 1))
+	if err := ioutil.WriteFile(defaultConfigFile(), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// without the passphrase, the encrypted file can't be written
+	if err := updateConfig(func(*SlyftRC) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	content, _ = ioutil.ReadFile(defaultConfigFile())
+	if !strings.Contains(string(content), "token") {
+		t.Errorf("Must keep the token in the config while the store fails, got %s", content)
+	}
+
+	os.Setenv("SLYFT_CREDENTIALS_PASSPHRASE", "secret")
+	defer os.Unsetenv("SLYFT_CREDENTIALS_PASSPHRASE")
+	loaded, err := readAuthFromConfig()
+	if err != nil || *loaded != *auth {
+		t.Errorf("Must move the kept credentials once the store works, got %#v (%v)", loaded, err)
+	}
+}
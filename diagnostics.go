@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severities of diagnostics.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// Diagnostic is a finding of a job, parsed from its result details.
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Asset    string `json:"asset,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+	Rule     string `json:"rule,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	var b bytes.Buffer
+	if d.Asset != "" {
+		b.WriteString(d.Asset + ":")
+		if d.Line > 0 {
+			fmt.Fprintf(&b, "%d:", d.Line)
+			if d.Column > 0 {
+				fmt.Fprintf(&b, "%d:", d.Column)
+			}
+		}
+		b.WriteString(" ")
+	}
+	fmt.Fprintf(&b, "%s: %s", d.Severity, d.Message)
+	if d.Rule != "" {
+		fmt.Fprintf(&b, " [%s]", d.Rule)
+	}
+	return b.String()
+}
+
+// reDiagnostic matches details like
+//
+//	api.yaml:12:5: error: Missing title [required-title]
+//	warning: api.raml:3: Unused type
+//	Something went wrong
+var reDiagnostic = regexp.MustCompile(`^(?:(?i:(error|warning|info|note))\s*:\s*)?` +
+	`(?:([^\s:][^:]*):(\d+)(?::(\d+))?:\s*)?` +
+	`(?:(?i:(error|warning|info|note))\s*:\s*)?` +
+	`(.*?)(?:\s*\[([\w./-]+)\])?$`)
+
+// parseDiagnostic parses a result detail. Details may be JSON objects
+// with the fields of Diagnostic, or text like reDiagnostic. Details
+// without severity get defaultSeverity.
+func parseDiagnostic(detail, defaultSeverity string) Diagnostic {
+	detail = strings.TrimSpace(detail)
+	if strings.HasPrefix(detail, "{") {
+		var d Diagnostic
+		if err := json.Unmarshal([]byte(detail), &d); err == nil && d.Message != "" {
+			d.Severity = normalizeSeverity(d.Severity, defaultSeverity)
+			return d
+		}
+	}
+
+	m := reDiagnostic.FindStringSubmatch(detail)
+	if m == nil {
+		return Diagnostic{Severity: defaultSeverity, Message: detail}
+	}
+	d := Diagnostic{Asset: m[2], Message: m[6], Rule: m[7]}
+	d.Line, _ = strconv.Atoi(m[3])
+	d.Column, _ = strconv.Atoi(m[4])
+	severity := m[1]
+	if severity == "" {
+		severity = m[5]
+	}
+	d.Severity = normalizeSeverity(severity, defaultSeverity)
+	if d.Message == "" {
+		d.Message = detail
+	}
+	return d
+}
+
+func normalizeSeverity(severity, defaultSeverity string) string {
+	switch strings.ToLower(severity) {
+	case "error", "fatal", "critical":
+		return severityError
+	case "warning", "warn":
+		return severityWarning
+	case "info", "note", "hint":
+		return severityInfo
+	}
+	return defaultSeverity
+}
+
+// Diagnostics returns the result details of the job as diagnostics.
+// Details without severity are errors if the job failed, and infos
+// otherwise, as successful jobs report what they did, e.g. "Generated 3
+// files". Only details marked as such are warnings.
+func (j *Job) Diagnostics() []Diagnostic {
+	defaultSeverity := severityInfo
+	if j.failed() {
+		defaultSeverity = severityError
+	}
+	diagnostics := make([]Diagnostic, 0, len(j.Results.ResultDetails))
+	for _, detail := range j.Results.ResultDetails {
+		if strings.TrimSpace(detail) != "" {
+			diagnostics = append(diagnostics, parseDiagnostic(detail, defaultSeverity))
+		}
+	}
+	return diagnostics
+}
+
+func displayDiagnostics(diagnostics []Diagnostic) {
+	if len(diagnostics) == 0 {
+		return
+	}
+	data := [][]string{{"Severity", "Asset", "Line", "Column", "Message", "Rule"}}
+	for _, d := range diagnostics {
+		line, column := "", ""
+		if d.Line > 0 {
+			line = strconv.Itoa(d.Line)
+		}
+		if d.Column > 0 {
+			column = strconv.Itoa(d.Column)
+		}
+		data = append(data, []string{d.Severity, d.Asset, line, column, d.Message, d.Rule})
+	}
+	fmt.Fprintf(os.Stdout, "%s%s",
+		markdownHeading("Diagnostics", 2),
+		markdownTable(&data))
+}
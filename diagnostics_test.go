@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/thingforward/slyft-cli/client"
+)
+
+func TestParseDiagnostic(t *testing.T) {
+	cases := []struct {
+		detail   string
+		expected Diagnostic
+	}{
+		{"api.yaml:12:5: error: Missing title [required-title]",
+			Diagnostic{Severity: "error", Asset: "api.yaml", Line: 12, Column: 5, Message: "Missing title", Rule: "required-title"}},
+		{"Warning: specs/api.raml:3: Unused type Person",
+			Diagnostic{Severity: "warning", Asset: "specs/api.raml", Line: 3, Message: "Unused type Person"}},
+		{"api.json:1:1: Unexpected token",
+			Diagnostic{Severity: "warning", Asset: "api.json", Line: 1, Column: 1, Message: "Unexpected token"}},
+		{"Generated 3 files",
+			Diagnostic{Severity: "warning", Message: "Generated 3 files"}},
+		{"info: see https://slyft.io/docs for details",
+			Diagnostic{Severity: "info", Message: "see https://slyft.io/docs for details"}},
+		{`{"severity": "fatal", "asset": "api.yaml", "line": 7, "message": "Broken", "rule": "syntax"}`,
+			Diagnostic{Severity: "error", Asset: "api.yaml", Line: 7, Message: "Broken", Rule: "syntax"}},
+	}
+	for _, c := range cases {
+		if d := parseDiagnostic(c.detail, "warning"); d != c.expected {
+			t.Errorf("%s: expected %#v, got %#v", c.detail, c.expected, d)
+		}
+	}
+}
+
+func TestJobDiagnosticsSeverity(t *testing.T) {
+	job := &Job{ID: 7, Kind: "build", Status: "processed", ProjectName: "alpha",
+		Results: client.JobResults{ResultStatus: 1, ResultDetails: []string{"Generated 3 files", "warning: Unused type"}}}
+	diagnostics := job.Diagnostics()
+	if diagnostics[0].Severity != severityInfo || diagnostics[1].Severity != severityWarning {
+		t.Errorf("Details of successful jobs must be infos unless marked, got %#v", diagnostics)
+	}
+
+	job.Results.ResultStatus = 2
+	if d := job.Diagnostics(); d[0].Severity != severityError {
+		t.Errorf("Details of failed jobs must be errors unless marked, got %#v", d)
+	}
+}
+
+func TestCodeClimatePath(t *testing.T) {
+	job := &Job{ID: 7, Kind: "validate", Status: "processed", ProjectName: "alpha",
+		Results: client.JobResults{ResultStatus: 2, ResultDetails: []string{"Project has no assets", "api.yaml:3: Broken"}}}
+	content, err := codeClimateReport(job, job.Diagnostics())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issues []codeClimateIssue
+	if err := json.Unmarshal(content, &issues); err != nil || len(issues) != 2 {
+		t.Fatalf("Expected two issues, got %v:\n%s", err, content)
+	}
+	if issues[0].Location.Path != "alpha" || issues[1].Location.Path != "api.yaml" {
+		t.Errorf("Expected paths alpha and api.yaml, got %#v", issues)
+	}
+}
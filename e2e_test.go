@@ -0,0 +1,1025 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"github.com/thingforward/slyft-cli/client"
+)
+
+// e2e sets up a logged in user in a temporary home directory, working
+// in a temporary directory, against a fake backend.
+type e2e struct {
+	t       *testing.T
+	fb      *fakeBackend
+	dir     string
+	code    int
+	stdout  string
+	stderr  string
+	cleanup []func()
+}
+
+func newE2E(t *testing.T) *e2e {
+	dir, err := ioutil.TempDir("", "slyft-e2e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &e2e{t: t, fb: newFakeBackend(), dir: dir}
+
+	e.setenv("HOME", dir)
+	e.setenv("SLYFTBACKEND", e.fb.URL)
+	e.setenv("SLYFT_UPDATE_CHECK", "off")
+	// the client config is read for API negotiation anyway
+	config := filepath.Join(dir, ".slyft-config.json")
+	if err := ioutil.WriteFile(config, []byte(`{"api_version": {"min": 1, "max": 1, "current": 1}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e.setenv("SLYFT_CONFIG_URL", config)
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	e.cleanup = append(e.cleanup, func() { os.Chdir(wd) })
+
+	if err := writeAuthToConfig(&e.fb.auth); err != nil {
+		t.Fatalf("Unable to write config: %v", err)
+	}
+	return e
+}
+
+func (e *e2e) setenv(key, value string) {
+	old, had := os.LookupEnv(key)
+	os.Setenv(key, value)
+	e.cleanup = append(e.cleanup, func() {
+		if had {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func (e *e2e) Close() {
+	for i := len(e.cleanup) - 1; i >= 0; i-- {
+		e.cleanup[i]()
+	}
+	e.fb.Close()
+	os.RemoveAll(e.dir)
+}
+
+// exitCode is raised by exit during tests.
+type exitCode int
+
+// run executes the slyft command line and returns everything written
+// to stdout, followed by everything written to stderr. Both are also
+// kept in e.stdout and e.stderr, the exit code in e.code.
+func (e *e2e) run(args ...string) string {
+	e.code = 0
+	exit = func(code int) { panic(exitCode(code)) }
+	defer func() { exit = cli.Exit }()
+
+	e.stdout, e.stderr = captureOutput(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				code, ok := r.(exitCode)
+				if !ok {
+					panic(r)
+				}
+				e.code = int(code)
+			}
+		}()
+		app := newApp()
+		app.ErrorHandling = flag.ContinueOnError
+		if err := app.Run(append([]string{"slyft"}, args...)); err != nil {
+			e.t.Errorf("slyft %s: %v", strings.Join(args, " "), err)
+		}
+	})
+	return e.stdout + e.stderr
+}
+
+func (e *e2e) writeFile(name, content string) {
+	if err := ioutil.WriteFile(filepath.Join(e.dir, name), []byte(content), 0644); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+func captureOutput(f func()) (string, string) {
+	capture := func(file **os.File) func() string {
+		r, w, err := os.Pipe()
+		if err != nil {
+			panic(err)
+		}
+		old := *file
+		*file = w
+		out := make(chan string)
+		go func() {
+			b, _ := ioutil.ReadAll(r)
+			out <- string(b)
+		}()
+		return func() string {
+			*file = old
+			w.Close()
+			return <-out
+		}
+	}
+	stdout, stderr := capture(&os.Stdout), capture(&os.Stderr)
+	defer func() {
+		if r := recover(); r != nil {
+			stdout()
+			stderr()
+			panic(r)
+		}
+	}()
+	f()
+	return stdout(), stderr()
+}
+
+func expectOutput(t *testing.T, out string, expected ...string) {
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("Expected output to contain %q, got:\n%s", e, out)
+		}
+	}
+}
+
+func TestE2EProjects(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	out := e.run("project", "create", "--name", "alpha", "--details", "First project")
+	expectOutput(t, out, "Project Details", "alpha", "First project")
+	e.run("project", "create", "--name", "alphabet")
+
+	var projects []Project
+	out = e.run("--output", "json", "project", "list")
+	if err := json.Unmarshal([]byte(e.stdout), &projects); err != nil || len(projects) != 2 {
+		t.Errorf("Expected two projects as JSON, got %v:\n%s", err, out)
+	}
+
+	// an exact name is not ambiguous, a portion is
+	expectOutput(t, e.run("project", "show", "--name", "alpha"), "First project")
+	expectOutput(t, e.run("project", "show", "--name", "alph"), "Candidates", "alphabet")
+
+	// errors and prompts go to stderr, so structured output stays parseable
+	e.run("--output", "json", "project", "show", "--name", "alph")
+	expectOutput(t, e.stderr, "failed", "Candidates")
+	if strings.TrimSpace(e.stdout) != "" {
+		t.Errorf("Expected no output on stdout for a failure, got:\n%s", e.stdout)
+	}
+	e.run("--output", "json", "project", "delete", "--name", "alphabet")
+	expectOutput(t, e.stderr, "refusing to delete without --yes")
+
+	expectOutput(t, e.run("project", "settings", "--name", "alphabet", "lang", "go"), "Successfully updated", `{"lang": "go"}`)
+
+	expectOutput(t, e.run("project", "delete", "--name", "alphabet"), "non-interactive")
+	if len(e.fb.projects) != 2 || e.code != exitUsage {
+		t.Errorf("Must not delete without confirmation, exit code %d", e.code)
+	}
+	expectOutput(t, e.run("--yes", "project", "delete", "--name", "alphabet"), "Was successfully deleted")
+	if len(e.fb.projects) != 1 {
+		t.Error("Must delete with --yes")
+	}
+}
+
+func TestE2EStructuredOutput(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	// stdout holds exactly one document, everything else goes to stderr
+	parse := func(v interface{}, args ...string) {
+		out := e.run(append([]string{"--output", "json"}, args...)...)
+		if err := json.Unmarshal([]byte(e.stdout), v); err != nil || e.code != 0 {
+			t.Errorf("slyft %s: expected one JSON document, got %v, exit code %d:\n%s",
+				strings.Join(args, " "), err, e.code, out)
+		}
+	}
+
+	var p Project
+	parse(&p, "project", "create", "--name", "alpha", "--details", "First project")
+	parse(&p, "project", "settings", "--name", "alpha", "lang", "go")
+	if p.Name != "alpha" {
+		t.Errorf("Expected the updated project, got %#v", p)
+	}
+
+	e.writeFile("api.json", `{"title": "My API"}`)
+	e.writeFile("spec.yaml", "title: My API\n")
+	var results []fileResult
+	parse(&results, "asset", "add", "--project", "alpha", "api.json", "spec.yaml")
+	if len(results) != 2 || results[0].Error != "" {
+		t.Errorf("Expected the results of two files, got %#v", results)
+	}
+
+	e.writeFile("new.json", `{"title": "New"}`)
+	var plan []SyncItem
+	parse(&plan, "asset", "sync", "--project", "alpha", "--dry-run")
+	if len(plan) != 3 {
+		t.Errorf("Expected a plan of three assets, got %#v", plan)
+	}
+	parse(&plan, "asset", "sync", "--project", "alpha", "--force")
+	expectOutput(t, e.stderr, "1 change(s) applied")
+
+	var updated []Asset
+	parse(&updated, "asset", "update", "--project", "alpha", "--force")
+
+	e.run("--output", "xml", "project", "list")
+	if e.code != exitUsage || e.stdout != "" || e.stderr == "" {
+		t.Errorf("Expected the error on stderr, got exit code %d, stdout %q", e.code, e.stdout)
+	}
+}
+
+func TestE2EAssets(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	e.run("project", "create", "--name", "alpha")
+	e.writeFile("api.json", `{"title": "My API"}`)
+	e.writeFile("spec.yaml", "title: My API\n")
+
+	out := e.run("asset", "add", "--project", "alpha", "api.json", "spec.yaml")
+	expectOutput(t, out, "Uploading api.json", "Uploading spec.yaml")
+	if len(e.fb.assets) != 2 {
+		t.Fatalf("Expected two assets, got %d", len(e.fb.assets))
+	}
+
+	// structural problems are only warnings, malformed assets are refused
+	e.writeFile("pets.json", `{"openapi": "3.0.3", "info": {"title": "Pets"}, "paths": {}}`)
+	e.run("asset", "add", "--project", "alpha", "pets.json")
+	expectOutput(t, e.stderr, "Warning: pets.json")
+	if len(e.fb.assets) != 3 || e.code != 0 {
+		t.Fatalf("Must upload despite warnings, got %d assets, exit code %d", len(e.fb.assets), e.code)
+	}
+	e.writeFile("broken.json", `{"openapi": `)
+	e.run("asset", "add", "--project", "alpha", "broken.json")
+	if len(e.fb.assets) != 3 || e.code != exitValidation {
+		t.Fatalf("Must refuse malformed assets, got %d assets, exit code %d", len(e.fb.assets), e.code)
+	}
+	for id, a := range e.fb.assets {
+		if a.Name == "pets.json" {
+			delete(e.fb.assets, id)
+		}
+	}
+
+	// a duplicate is only overwritten when confirmed
+	e.writeFile("api.json", `{"title": "My new API"}`)
+	e.run("asset", "add", "--project", "alpha", "api.json")
+	if string(e.fb.content[2]) != `{"title": "My API"}` {
+		t.Errorf("Must not overwrite without confirmation, got %s", e.fb.content[2])
+	}
+	e.run("--yes", "asset", "add", "--project", "alpha", "api.json")
+	if string(e.fb.content[2]) != `{"title": "My new API"}` {
+		t.Errorf("Must overwrite with --yes, got %s", e.fb.content[2])
+	}
+
+	os.Remove(filepath.Join(e.dir, "api.json"))
+	expectOutput(t, e.run("asset", "get", "--project", "alpha", "api.json"), "Downloaded api.json")
+	if content, err := ioutil.ReadFile(filepath.Join(e.dir, "api.json")); err != nil || string(content) != `{"title": "My new API"}` {
+		t.Errorf("Downloaded file does not match, got %s (%v)", content, err)
+	}
+
+	var assets []Asset
+	out = e.run("--output", "json", "asset", "list", "--project", "alpha")
+	if err := json.Unmarshal([]byte(e.stdout), &assets); err != nil || len(assets) != 2 {
+		t.Errorf("Expected two assets as JSON, got %v:\n%s", err, out)
+	}
+
+	expectOutput(t, e.run("asset", "delete", "--project", "alpha", "spec.yaml"), "Was successfully deleted")
+	if len(e.fb.assets) != 1 {
+		t.Errorf("Expected one asset after delete, got %d", len(e.fb.assets))
+	}
+}
+
+func TestE2EJobs(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	e.run("project", "create", "--name", "alpha")
+	expectOutput(t, e.run("project", "build", "--project", "alpha"), "is started")
+	if len(e.fb.jobs) != 1 {
+		t.Fatalf("Expected one job, got %d", len(e.fb.jobs))
+	}
+
+	expectOutput(t, e.run("project", "status", "--project", "alpha"), "Job Details", "build", "queued")
+}
+
+func TestE2ELogout(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	expectOutput(t, e.run("user", "logout"), "Bye for now")
+	if auth, _ := readAuthFromConfig(); auth.GoodForLogin() {
+		t.Errorf("Must be logged out, got %#v", auth)
+	}
+}
+
+func TestE2ESync(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	e.run("project", "create", "--name", "alpha")
+	os.Mkdir(filepath.Join(e.dir, "specs"), 0755)
+	e.writeFile("api.json", `{"title": "My API"}`)
+	e.writeFile("specs/spec.yaml", "title: My API\n")
+	e.writeFile("notes.txt", "not an asset")
+
+	out := e.run("asset", "sync", "--project", "alpha", "--dry-run")
+	expectOutput(t, out, "api.json", "specs/spec.yaml", "new", "upload")
+	if len(e.fb.assets) != 0 {
+		t.Fatalf("Must not upload on --dry-run, got %d assets", len(e.fb.assets))
+	}
+
+	expectOutput(t, e.run("asset", "sync", "--project", "alpha", "--force"), "2 change(s) applied")
+	if len(e.fb.assets) != 2 {
+		t.Fatalf("Expected two assets, got %d", len(e.fb.assets))
+	}
+	expectOutput(t, e.run("asset", "sync", "--project", "alpha", "--force"), "Everything is in sync")
+
+	// without --delete, removals on either side are only reported
+	os.Remove(filepath.Join(e.dir, "api.json"))
+	for id, a := range e.fb.assets {
+		if a.Name == "specs/spec.yaml" {
+			delete(e.fb.assets, id)
+		}
+	}
+	out = e.run("asset", "sync", "--project", "alpha", "--force")
+	expectOutput(t, out, "deleted-locally", "deleted-remotely", "Everything is in sync")
+
+	expectOutput(t, e.run("asset", "sync", "--project", "alpha", "--force", "--delete"), "2 change(s) applied")
+	if len(e.fb.assets) != 0 {
+		t.Errorf("Must delete the remote asset, got %d assets", len(e.fb.assets))
+	}
+	if _, err := os.Stat(filepath.Join(e.dir, "specs", "spec.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Must delete the local file, got %v", err)
+	}
+}
+
+func TestE2ESyncKeepsIgnoredAssets(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	e.run("project", "create", "--name", "alpha")
+	e.writeFile("api.json", `{"title": "My API"}`)
+	e.writeFile("draft.json", `{"title": "Draft"}`)
+	e.writeFile(".hidden.json", `{"title": "Hidden"}`)
+	e.run("asset", "add", "--project", "alpha", "api.json", "draft.json", ".hidden.json")
+	if len(e.fb.assets) != 3 {
+		t.Fatalf("Expected three assets, got %d", len(e.fb.assets))
+	}
+
+	// ignored and hidden files are no deletions, even if they still exist
+	e.writeFile(".slyftignore", "draft.json\n")
+	out := e.run("asset", "sync", "--project", "alpha", "--delete", "--force")
+	expectOutput(t, out, "draft.json", "ignored", "Everything is in sync")
+	if len(e.fb.assets) != 3 {
+		t.Errorf("Must not delete ignored or hidden assets, got %d assets:\n%s", len(e.fb.assets), out)
+	}
+
+	os.Remove(filepath.Join(e.dir, "api.json"))
+	os.Remove(filepath.Join(e.dir, "draft.json"))
+	expectOutput(t, e.run("asset", "sync", "--project", "alpha", "--delete", "--force"), "1 change(s) applied")
+	if len(e.fb.assets) != 2 {
+		t.Errorf("Must only delete the asset of the removed file, got %d assets", len(e.fb.assets))
+	}
+}
+
+func TestE2EUpdateByDigest(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	e.run("project", "create", "--name", "alpha")
+	e.writeFile("api.json", `{"title": "My API"}`)
+	e.run("asset", "add", "--project", "alpha", "api.json")
+
+	// a checkout touches the file without changing it
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(e.dir, "api.json"), future, future)
+	requests := len(e.fb.requests)
+	expectOutput(t, e.run("asset", "update", "--project", "alpha", "--force"), "api.json is up-to-date")
+	for _, r := range e.fb.requests[requests:] {
+		if strings.HasPrefix(r, "POST") || strings.HasPrefix(r, "PUT") {
+			t.Errorf("Must not upload an unchanged file, got %s", r)
+		}
+	}
+
+	// a change is detected even if the file looks older than the asset
+	e.writeFile("api.json", `{"title": "My new API"}`)
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(e.dir, "api.json"), past, past)
+	e.run("asset", "update", "--project", "alpha", "--force")
+	if string(e.fb.content[2]) != `{"title": "My new API"}` {
+		t.Errorf("Must upload the changed file, got %s", e.fb.content[2])
+	}
+
+	// without a recorded digest, the content on the server is compared
+	os.Remove(filepath.Join(e.dir, ".slyftstate"))
+	os.Chtimes(filepath.Join(e.dir, "api.json"), future, future)
+	requests = len(e.fb.requests)
+	expectOutput(t, e.run("asset", "update", "--project", "alpha", "--force"), "api.json is up-to-date")
+	for _, r := range e.fb.requests[requests:] {
+		if strings.HasPrefix(r, "POST") || strings.HasPrefix(r, "PUT") {
+			t.Errorf("Must not upload a file matching the server, got %s", r)
+		}
+	}
+	os.Remove(filepath.Join(e.dir, ".slyftstate"))
+	e.writeFile("api.json", `{"title": "My newest API"}`)
+	os.Chtimes(filepath.Join(e.dir, "api.json"), past, past)
+	e.run("asset", "update", "--project", "alpha", "--force")
+	if string(e.fb.content[2]) != `{"title": "My newest API"}` {
+		t.Errorf("Must upload a file differing from the server, got %s", e.fb.content[2])
+	}
+
+	// assets without a local file are skipped, not failed
+	os.Remove(filepath.Join(e.dir, "api.json"))
+	expectOutput(t, e.run("asset", "update", "--project", "alpha", "--force"), "Unable to check update for api.json")
+	if e.code != 0 {
+		t.Errorf("Must not fail for assets without a local file, exit code %d", e.code)
+	}
+}
+
+func TestE2EParallelAssets(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	e.run("project", "create", "--name", "alpha")
+	names := []string{"a.json", "b.json", "c.json", "d.json", "e.json"}
+	for _, name := range names {
+		e.writeFile(name, `{"name": "`+name+`"}`)
+	}
+
+	out := e.run(append([]string{"asset", "add", "--project", "alpha", "--parallel", "3"}, names...)...)
+	expectOutput(t, out, "| FILE", "| e.json   | ok")
+	if len(e.fb.assets) != len(names) {
+		t.Fatalf("Expected %d assets, got %d", len(names), len(e.fb.assets))
+	}
+
+	for _, name := range names {
+		os.Remove(filepath.Join(e.dir, name))
+	}
+	e.run(append([]string{"asset", "get", "--project", "alpha", "--parallel", "3"}, names...)...)
+	for _, name := range names {
+		if content, err := ioutil.ReadFile(filepath.Join(e.dir, name)); err != nil || string(content) != `{"name": "`+name+`"}` {
+			t.Errorf("Downloaded %s does not match, got %s (%v)", name, content, err)
+		}
+	}
+}
+
+func TestE2ERecursiveAdd(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	e.run("project", "create", "--name", "alpha")
+	os.MkdirAll(filepath.Join(e.dir, "specs", "fragments"), 0755)
+	e.writeFile("specs/api.raml", "#%RAML 1.0\ntitle: My API\n")
+	e.writeFile("specs/fragments/person.raml", "#%RAML 1.0 DataType\ntype: object\n")
+	e.writeFile("specs/fragments/old.raml", "#%RAML 1.0 DataType\ntype: object\n")
+
+	expectOutput(t, e.run("asset", "add", "--project", "alpha", "specs"), "use --recursive")
+	if len(e.fb.assets) != 0 {
+		t.Fatalf("Must not upload a directory without --recursive, got %d assets", len(e.fb.assets))
+	}
+
+	e.run("asset", "add", "--project", "alpha", "--recursive", "--exclude", "old.*", "specs")
+	names := make([]string, 0)
+	for _, a := range e.fb.assetList(0) {
+		names = append(names, a.Name)
+	}
+	if strings.Join(names, ",") != "api.raml,fragments/person.raml" {
+		t.Errorf("Must upload with paths relative to the directory as names, got %v", names)
+	}
+
+	// an absolute directory yields the same names, which sync agrees with
+	for id := range e.fb.assets {
+		delete(e.fb.assets, id)
+	}
+	e.run("asset", "add", "--project", "alpha", "--recursive", "--exclude", "old.*", filepath.Join(e.dir, "specs"))
+	names = names[:0]
+	for _, a := range e.fb.assetList(0) {
+		names = append(names, a.Name)
+	}
+	if strings.Join(names, ",") != "api.raml,fragments/person.raml" {
+		t.Errorf("Must not name assets after an absolute path, got %v", names)
+	}
+	os.Remove(filepath.Join(e.dir, "specs", "fragments", "old.raml"))
+	expectOutput(t, e.run("asset", "sync", "--project", "alpha", "specs"), "Everything is in sync")
+}
+
+func TestE2ECheckOffline(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	// neither the config nor the backend are needed
+	os.Remove(defaultConfigFile())
+	e.fb.Close()
+	e.setenv("SLYFT_UPDATE_CHECK", "on")
+	e.setenv("SLYFT_CONFIG_URL", e.fb.URL)
+
+	e.writeFile("api.json", `{"openapi": "3.0.3", "info": {"title": "Pets"}, "paths": {}}`)
+	e.writeFile("types.raml", "#%RAML 1.0 DataType\ntype: object\n")
+	os.Mkdir(filepath.Join(e.dir, "specs"), 0755)
+	e.writeFile("specs/spec.yaml", "a: [1\n")
+
+	var results []CheckResult
+	out := e.run("--output", "json", "asset", "check", "api.json", "types.raml", "specs")
+	if err := json.Unmarshal([]byte(e.stdout), &results); err != nil || len(results) != 3 {
+		t.Fatalf("Expected three results as JSON, got %v:\n%s", err, out)
+	}
+	if len(results[0].Problems) != 1 || len(results[1].Problems) != 0 || results[2].File != "specs/spec.yaml" || len(results[2].Problems) != 1 {
+		t.Errorf("Unexpected results %#v", results)
+	}
+	if e.code != exitValidation {
+		t.Errorf("Must exit with exitValidation on problems, got %d", e.code)
+	}
+	if len(e.fb.requests) != 0 {
+		t.Errorf("Must not contact the server, got %v", e.fb.requests)
+	}
+
+	e.run("asset", "check", "types.raml")
+	if e.code != 0 {
+		t.Errorf("Must exit with 0 without problems, got %d", e.code)
+	}
+}
+
+func TestE2EChunkedUpload(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+	e.setenv("SLYFT_RETRIES", "0")
+
+	e.run("project", "create", "--name", "alpha")
+	items := make([]string, 0)
+	for i := 0; i < 3000; i++ {
+		items = append(items, strconv.Quote(fmt.Sprintf("item %d", i)))
+	}
+	large := `{"items": [` + strings.Join(items, ", ") + `]}`
+	e.writeFile("large.json", large)
+	chunks := (len(large) + 8191) / 8192
+
+	// the second chunk fails, the next run resumes from there
+	e.fb.failChunk = 2
+	e.run("asset", "add", "--project", "alpha", "large.json")
+	if len(e.fb.assets) != 0 || e.code == 0 {
+		t.Fatalf("Must fail when a chunk fails, got %d assets, exit code %d", len(e.fb.assets), e.code)
+	}
+	requests := len(e.fb.requests)
+	expectOutput(t, e.run("asset", "add", "--project", "alpha", "large.json"), "Resuming upload of large.json at 8192")
+	puts := 0
+	for _, r := range e.fb.requests[requests:] {
+		if strings.HasPrefix(r, "PUT") {
+			puts++
+		}
+	}
+	if puts != chunks-1 {
+		t.Errorf("Must only send the remaining %d chunks, sent %d", chunks-1, puts)
+	}
+	if len(e.fb.assets) != 1 || string(e.fb.content[e.fb.nextID]) != large {
+		t.Fatalf("Must upload the whole file, got %d assets", len(e.fb.assets))
+	}
+
+	// overwriting goes through chunks as well
+	e.writeFile("large.json", strings.Replace(large, "item 1", "ITEM 1", -1))
+	e.run("--yes", "asset", "add", "--project", "alpha", "large.json")
+	for _, content := range e.fb.content {
+		if !strings.Contains(string(content), "ITEM 1") {
+			t.Errorf("Must overwrite the asset")
+		}
+	}
+
+	e.fb.chunkSize = 0
+	expectOutput(t, e.run("asset", "add", "--project", "alpha", "large.json"), "does not support chunked uploads")
+}
+
+func TestE2EGetPaths(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	e.run("project", "create", "--name", "alpha")
+	os.MkdirAll(filepath.Join(e.dir, "specs"), 0755)
+	e.writeFile("specs/api.json", `{"title": "My API"}`)
+	e.run("asset", "add", "--project", "alpha", "specs/api.json")
+	now := time.Now().UTC()
+	for _, name := range []string{"../evil.json", "/tmp/evil.json"} {
+		a := &Asset{ID: e.fb.id(), Name: name, ProjectId: 1, ProjectName: "alpha", CreatedAt: now, UpdatedAt: now}
+		e.fb.assets[a.ID], e.fb.content[a.ID] = a, []byte(`{}`)
+	}
+
+	out := e.run("asset", "get", "--project", "alpha", "--output-dir", "out", "specs/api.json", "../evil.json", "/tmp/evil.json")
+	expectOutput(t, out, "outside of out")
+	if e.code != exitUsage {
+		t.Errorf("Must fail for names outside of the output directory, exit code %d", e.code)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(e.dir, "out", "specs", "api.json")); err != nil || string(content) != `{"title": "My API"}` {
+		t.Errorf("Must create the directories of the asset, got %s (%v)", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(e.dir, "evil.json")); !os.IsNotExist(err) {
+		t.Errorf("Must not write outside of the output directory")
+	}
+
+	// unchanged files are overwritten, changed ones only with --force
+	expectOutput(t, e.run("asset", "get", "--project", "alpha", "specs/api.json"), "Downloaded specs/api.json")
+	e.writeFile("specs/api.json", `{"title": "My changed API"}`)
+	expectOutput(t, e.run("asset", "get", "--project", "alpha", "specs/api.json"), "has local modifications")
+	if content, _ := ioutil.ReadFile(filepath.Join(e.dir, "specs", "api.json")); string(content) != `{"title": "My changed API"}` || e.code != exitConflict {
+		t.Errorf("Must not overwrite local modifications, got %s", content)
+	}
+	e.run("asset", "get", "--project", "alpha", "--force", "specs/api.json")
+	if content, _ := ioutil.ReadFile(filepath.Join(e.dir, "specs", "api.json")); string(content) != `{"title": "My API"}` {
+		t.Errorf("Must overwrite with --force, got %s", content)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(e.dir, "specs", ".*.tmp*")); len(matches) > 0 {
+		t.Errorf("Must not leave temporary files, found %v", matches)
+	}
+}
+
+func TestE2EArtifacts(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	e.run("project", "create", "--name", "alpha")
+	e.run("project", "build", "--project", "alpha")
+	expectOutput(t, e.run("project", "artifacts", "--project", "alpha"), "has no finished build")
+	var job *Job
+	for _, j := range e.fb.jobs {
+		job = j
+	}
+	id := strconv.Itoa(job.ID)
+	expectOutput(t, e.run("project", "artifacts", "--project", "alpha", "--job", id), "is not finished yet")
+	if e.code != exitIncomplete {
+		t.Errorf("Must fail for unfinished jobs, exit code %d", e.code)
+	}
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	w, _ := zw.Create("src/api.c")
+	w.Write([]byte("int main() {}\n"))
+	zw.Close()
+	now := time.Now().UTC()
+	a := &Asset{ID: e.fb.id(), Name: "generated.zip", ProjectId: job.ProjectId, ProjectName: "alpha", CreatedAt: now, UpdatedAt: now}
+	e.fb.assets[a.ID], e.fb.content[a.ID] = a, buf.Bytes()
+
+	expectOutput(t, e.run("project", "artifacts", "--project", "alpha", "--output-dir", "out", "--extract"), "Downloaded out/generated.zip", "Extracted out/generated.zip")
+	if content, err := ioutil.ReadFile(filepath.Join(e.dir, "out", "src", "api.c")); err != nil || string(content) != "int main() {}\n" {
+		t.Errorf("Must extract the archive, got %s (%v)", content, err)
+	}
+
+	// extracted files changed locally are only overwritten with --force or --yes
+	api := filepath.Join(e.dir, "out", "src", "api.c")
+	ioutil.WriteFile(api, []byte("changed"), 0644)
+	expectOutput(t, e.run("project", "artifacts", "--project", "alpha", "--output-dir", "out", "--extract"), "already exists, use --force")
+	if content, _ := ioutil.ReadFile(api); string(content) != "changed" || e.code != exitConflict {
+		t.Errorf("Must not overwrite existing files, got %s, exit code %d", content, e.code)
+	}
+	for _, flag := range []string{"--force", "--yes"} {
+		ioutil.WriteFile(api, []byte("changed"), 0644)
+		args := []string{"project", "artifacts", "--project", "alpha", "--output-dir", "out", "--extract", flag}
+		if flag == "--yes" {
+			args = append([]string{flag}, args[:len(args)-1]...)
+		}
+		expectOutput(t, e.run(args...), "Extracted out/generated.zip")
+		if content, _ := ioutil.ReadFile(api); string(content) != "int main() {}\n" {
+			t.Errorf("Must overwrite existing files with %s, got %s", flag, content)
+		}
+	}
+
+	// archive entries must stay below the output directory
+	buf.Reset()
+	zw = zip.NewWriter(buf)
+	w, _ = zw.Create("../evil.c")
+	w.Write([]byte("evil"))
+	zw.Close()
+	e.fb.content[a.ID] = buf.Bytes()
+	expectOutput(t, e.run("project", "artifacts", "--project", "alpha", "--job", id, "--output-dir", "out", "--extract"), "outside of out")
+	if _, err := os.Stat(filepath.Join(e.dir, "evil.c")); !os.IsNotExist(err) || e.code != exitUsage {
+		t.Errorf("Must not extract outside of the output directory, exit code %d", e.code)
+	}
+}
+
+func TestE2EFollowJob(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+	defer func(interval, max time.Duration) {
+		followInterval, followMaxInterval = interval, max
+	}(followInterval, followMaxInterval)
+	followInterval, followMaxInterval = time.Millisecond, 2*time.Millisecond
+
+	e.run("project", "create", "--name", "alpha")
+	out := e.run("project", "build", "--project", "alpha", "--follow")
+	expectOutput(t, out, "Job 2 is queued", "Job 2 is processed", "Job Details", "generated.zip")
+	if e.code != 0 {
+		t.Errorf("Must succeed for a successful build, exit code %d", e.code)
+	}
+
+	e.fb.jobResult = &client.JobResults{ResultMessage: "Syntax error", ResultStatus: 2}
+	expectOutput(t, e.run("project", "validate", "--project", "alpha", "--follow"), "Job 3 is processed", "Syntax error")
+	if e.code != exitValidation {
+		t.Errorf("Must fail for a failed validation, exit code %d", e.code)
+	}
+
+	var job Job
+	out = e.run("--output", "json", "project", "build", "--project", "alpha", "--follow")
+	if err := json.Unmarshal([]byte(e.stdout), &job); err != nil || job.Status != "processed" {
+		t.Errorf("Must display the finished job as JSON, got %v:\n%s", err, out)
+	}
+
+	// with an interval longer than --wait, the job is still polled at the deadline
+	e.fb.holdJobs = true
+	followInterval, followMaxInterval = 10*time.Second, 10*time.Second
+	start := time.Now()
+	expectOutput(t, e.run("project", "build", "--project", "alpha", "--wait", "1"), "Job 5 did not complete in time")
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 5*time.Second {
+		t.Errorf("Must give up at the deadline, gave up after %v", elapsed)
+	}
+	if last := e.fb.requests[len(e.fb.requests)-1]; last != "GET /v1/projects/1/jobs/5" {
+		t.Errorf("Must poll the job at the deadline, last request was %s", last)
+	}
+}
+
+func TestE2EReports(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+	defer func(interval time.Duration) { followInterval = interval }(followInterval)
+	followInterval = time.Millisecond
+
+	e.run("project", "create", "--name", "alpha")
+	e.fb.jobResult = &client.JobResults{ResultMessage: "Invalid", ResultStatus: 2, ResultDetails: []string{
+		"api.yaml:12:5: error: Missing title [required-title]",
+		"api.yaml:20: warning: Unused definition Person",
+	}}
+
+	out := e.run("project", "validate", "--project", "alpha", "--report", "sarif", "report.sarif")
+	expectOutput(t, out, "| error", "Missing title", "Wrote sarif report to report.sarif")
+	if e.code != exitValidation {
+		t.Errorf("Must fail for a failed validation, exit code %d", e.code)
+	}
+	var sarif sarifLog
+	content, _ := ioutil.ReadFile(filepath.Join(e.dir, "report.sarif"))
+	if err := json.Unmarshal(content, &sarif); err != nil || len(sarif.Runs) != 1 || len(sarif.Runs[0].Results) != 2 {
+		t.Fatalf("Expected SARIF with two results, got %v:\n%s", err, content)
+	}
+	if r := sarif.Runs[0].Results[0]; r.RuleID != "required-title" || r.Level != "error" ||
+		r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "api.yaml" || r.Locations[0].PhysicalLocation.Region.StartLine != 12 {
+		t.Errorf("Unexpected SARIF result %#v", r)
+	}
+
+	e.run("project", "status", "--project", "alpha", "--report", "junit", "report.xml")
+	var junit junitTestSuites
+	content, _ = ioutil.ReadFile(filepath.Join(e.dir, "report.xml"))
+	if err := xml.Unmarshal(content, &junit); err != nil || len(junit.Suites) != 1 || junit.Suites[0].Failures != 1 {
+		t.Fatalf("Expected JUnit report with one failure, got %v:\n%s", err, content)
+	}
+
+	e.run("project", "status", "--project", "alpha", "--report", "codeclimate", "report.json")
+	var issues []codeClimateIssue
+	content, _ = ioutil.ReadFile(filepath.Join(e.dir, "report.json"))
+	if err := json.Unmarshal(content, &issues); err != nil || len(issues) != 2 || issues[1].Severity != "minor" || issues[1].Location.Lines.Begin != 20 {
+		t.Errorf("Expected two Code Climate issues, got %v:\n%s", err, content)
+	}
+
+	expectOutput(t, e.run("project", "status", "--project", "alpha", "--report", "pdf", "report.pdf"), "Unknown report format pdf")
+	if e.code != exitUsage {
+		t.Errorf("Must fail for unknown formats, exit code %d", e.code)
+	}
+}
+
+func TestE2EExitCodes(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+
+	e.run("project", "create", "--name", "alpha")
+	e.run("project", "show", "--name", "alpha")
+	if e.code != 0 {
+		t.Errorf("Must succeed, exit code %d", e.code)
+	}
+
+	expectOutput(t, e.run("project", "show", "--name", "beta"), "No such project")
+	if e.code != exitNotFound {
+		t.Errorf("Expected exit code %d for a missing project, got %d", exitNotFound, e.code)
+	}
+
+	e.run("asset", "delete", "--project", "alpha", "missing.json")
+	if e.code != exitNotFound {
+		t.Errorf("Expected exit code %d for a missing asset, got %d", exitNotFound, e.code)
+	}
+
+	e.run("--output", "xml", "project", "list")
+	if e.code != exitUsage {
+		t.Errorf("Expected exit code %d for a wrong option, got %d", exitUsage, e.code)
+	}
+
+	e.setenv("SLYFT_RETRIES", "0")
+	e.setenv("SLYFTBACKEND", "http://127.0.0.1:1/")
+	e.run("project", "list")
+	if e.code != exitNetwork {
+		t.Errorf("Expected exit code %d when the server is unreachable, got %d", exitNetwork, e.code)
+	}
+	e.setenv("SLYFTBACKEND", e.fb.URL)
+
+	e.run("user", "logout")
+	expectOutput(t, e.run("project", "list"), "You do not seem to be logged in")
+	if e.code != exitAuth {
+		t.Errorf("Expected exit code %d when logged out, got %d", exitAuth, e.code)
+	}
+}
+
+func TestE2EJobCommands(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+	defer func(interval, max time.Duration) {
+		followInterval, followMaxInterval = interval, max
+	}(followInterval, followMaxInterval)
+	followInterval, followMaxInterval = time.Millisecond, 10*time.Millisecond
+
+	e.run("project", "create", "--name", "alpha")
+	e.fb.holdJobs = true
+	e.run("project", "build", "--project", "alpha")
+	e.run("project", "validate", "--project", "alpha")
+	e.run("project", "build", "--project", "alpha")
+
+	var jobs []Job
+	out := e.run("--output", "json", "job", "list", "--project", "alpha", "--kind", "build")
+	if err := json.Unmarshal([]byte(e.stdout), &jobs); err != nil || len(jobs) != 2 || jobs[0].ID != 4 || jobs[1].ID != 2 {
+		t.Errorf("Expected builds 4 and 2, newest first, got %v:\n%s", err, out)
+	}
+	expectOutput(t, e.run("job", "list", "--project", "alpha", "--limit", "1"), "Job Details", "| Id            | 4")
+	expectOutput(t, e.run("job", "list", "--project", "alpha", "--status", "processed"), "No jobs found")
+	expectOutput(t, e.run("job", "list", "--project", "alpha", "--since", "yesterday"), "Unable to parse --since")
+	if e.code != exitUsage {
+		t.Errorf("Expected exit code %d for a wrong --since, got %d", exitUsage, e.code)
+	}
+	expectOutput(t, e.run("job", "show", "--project", "alpha", "3"), "validate", "queued")
+	e.run("job", "show", "--project", "alpha", "42")
+	if e.code != exitNotFound {
+		t.Errorf("Expected exit code %d for a missing job, got %d", exitNotFound, e.code)
+	}
+
+	// hanging jobs can be waited for with a timeout, and cancelled
+	start := time.Now()
+	expectOutput(t, e.run("job", "wait", "--project", "alpha", "--timeout", "1", "4"), "did not complete in time")
+	if e.code != exitIncomplete || time.Since(start) > 5*time.Second {
+		t.Errorf("Expected exit code %d after the timeout, got %d", exitIncomplete, e.code)
+	}
+	expectOutput(t, e.run("job", "retry", "--project", "alpha", "4"), "is still queued")
+	if e.code != exitConflict {
+		t.Errorf("Expected exit code %d when retrying a running job, got %d", exitConflict, e.code)
+	}
+	e.fb.noCancel = true
+	expectOutput(t, e.run("job", "cancel", "--project", "alpha", "4"), "does not support cancelling jobs")
+	if e.code != exitUnsupported {
+		t.Errorf("Expected exit code %d if the server can't cancel jobs, got %d", exitUnsupported, e.code)
+	}
+	e.fb.noCancel = false
+	expectOutput(t, e.run("job", "cancel", "--project", "alpha", "4"), "Job 4 is cancelled")
+	expectOutput(t, e.run("job", "cancel", "--project", "alpha", "4"), "already cancelled")
+	if e.code != exitConflict {
+		t.Errorf("Expected exit code %d when cancelling a cancelled job, got %d", exitConflict, e.code)
+	}
+
+	e.fb.holdJobs = false
+	expectOutput(t, e.run("job", "retry", "--project", "alpha", "--follow", "4"), "Job 5 is processed", "generated.zip")
+	if job := e.fb.jobs[5]; job == nil || job.Kind != "build" || e.code != 0 {
+		t.Errorf("Must start a new build, got %#v, exit code %d", job, e.code)
+	}
+	e.run("job", "wait", "--project", "alpha", "3")
+	if e.fb.jobs[3].Status != "processed" || e.code != 0 {
+		t.Errorf("Must wait for the job, got %s, exit code %d", e.fb.jobs[3].Status, e.code)
+	}
+}
+
+func TestE2EJobRun(t *testing.T) {
+	e := newE2E(t)
+	defer e.Close()
+	defer func(interval, max time.Duration) {
+		followInterval, followMaxInterval = interval, max
+	}(followInterval, followMaxInterval)
+	followInterval, followMaxInterval = time.Millisecond, 10*time.Millisecond
+
+	e.run("project", "create", "--name", "alpha")
+
+	// without a list from the server, kinds come from the config
+	expectOutput(t, e.run("job", "kinds"), "build", "validate", "does not list")
+	e.run("job", "run", "--project", "alpha", "docs")
+	if e.code != exitValidation {
+		t.Errorf("Expected exit code %d for a kind the server rejects, got %d", exitValidation, e.code)
+	}
+
+	e.fb.jobKinds = []JobKind{{Name: "build"}, {Name: "validate"}, {Name: "docs", Description: "HTML documentation"}, {Name: "codegen", Description: "Code for a target"}}
+	expectOutput(t, e.run("job", "kinds"), "codegen", "HTML documentation")
+	expectOutput(t, e.run("job", "run", "--project", "alpha", "mocks"), "Unknown job kind mocks", "codegen, docs")
+	if e.code != exitUsage {
+		t.Errorf("Expected exit code %d for an unknown kind, got %d", exitUsage, e.code)
+	}
+	expectOutput(t, e.run("job", "run", "--project", "alpha", "--param", "target", "codegen"), "Invalid parameter target")
+	if e.code != exitUsage {
+		t.Errorf("Expected exit code %d for a wrong parameter, got %d", exitUsage, e.code)
+	}
+
+	e.writeFile("params.yaml", "target: java\npackage: io.slyft\nversion: 2\n")
+	expectOutput(t, e.run("job", "run", "--project", "alpha", "--params-file", e.dir+"/params.yaml",
+		"--param", "target=go", "-P", "strict=true", "--follow", "codegen"), "Job 2 is processed", "Params[target]")
+	job := e.fb.jobs[2]
+	if job == nil || job.Kind != "codegen" || e.code != 0 {
+		t.Fatalf("Must start a codegen job, got %#v, exit code %d", job, e.code)
+	}
+	expected := map[string]interface{}{"target": "go", "package": "io.slyft", "version": 2.0, "strict": "true"}
+	if !reflect.DeepEqual(job.Params, expected) {
+		t.Errorf("Expected params %v, got %v", expected, job.Params)
+	}
+
+	// retries keep the parameters
+	e.run("job", "retry", "--project", "alpha", "2")
+	if retry := e.fb.jobs[3]; retry == nil || !reflect.DeepEqual(retry.Params, expected) {
+		t.Errorf("Must retry with params %v, got %#v", expected, retry)
+	}
+}
+
+func TestE2EWatch(t *testing.T) {
+	t.Run("notify", func(t *testing.T) { testE2EWatch(t, watchChanges) })
+	t.Run("poll", func(t *testing.T) { testE2EWatch(t, pollChanges) })
+}
+
+func testE2EWatch(t *testing.T, changes func(string, <-chan struct{}) (<-chan struct{}, error)) {
+	e := newE2E(t)
+	defer e.Close()
+	defer func(debounce, poll, interval time.Duration, watch func(string, <-chan struct{}) (<-chan struct{}, error), interrupt func() <-chan struct{}) {
+		watchDebounce, watchPollInterval, followInterval, watchChanges, watchInterrupt = debounce, poll, interval, watch, interrupt
+	}(watchDebounce, watchPollInterval, followInterval, watchChanges, watchInterrupt)
+	watchDebounce, watchPollInterval, followInterval = 50*time.Millisecond, 20*time.Millisecond, time.Millisecond
+	watchChanges = changes
+	stop := make(chan struct{})
+	watchInterrupt = func() <-chan struct{} { return stop }
+
+	e.run("project", "create", "--name", "alpha")
+	os.Mkdir(filepath.Join(e.dir, "specs"), 0755)
+	e.writeFile("specs/api.json", `{"title": "My API"}`)
+
+	// waitFor waits until cond holds for the fake backend
+	waitFor := func(what string, cond func() bool) {
+		for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			e.fb.mu.Lock()
+			ok := cond()
+			e.fb.mu.Unlock()
+			if ok {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Errorf("Timed out waiting for %s", what)
+				return
+			}
+		}
+	}
+	validated := func(status int) func() bool {
+		return func() bool {
+			for _, j := range e.fb.jobs {
+				if j.Kind == "validate" && j.Status == "processed" && j.Results.ResultStatus == status {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	go func() {
+		defer close(stop)
+		waitFor("the first validation", validated(1))
+
+		e.fb.mu.Lock()
+		e.fb.jobResult = &client.JobResults{ResultMessage: "Invalid", ResultStatus: 2,
+			ResultDetails: []string{"api.json:1:2: error: Missing version"}}
+		e.fb.mu.Unlock()
+		e.writeFile("specs/api.json", `{"title": "My new API"}`)
+		os.Mkdir(filepath.Join(e.dir, "specs", "v2"), 0755)
+		e.writeFile("specs/v2/spec.yaml", "title: My API\n")
+
+		waitFor("the changes", func() bool { return len(e.fb.assets) == 2 })
+		waitFor("the second validation", validated(2))
+		// give the result time to be shown
+		time.Sleep(200 * time.Millisecond)
+	}()
+	out := e.run("project", "watch", "--project", "alpha", "specs")
+
+	expectOutput(t, out, "Saving asset api.json", "Saving asset v2/spec.yaml",
+		"OK, 0 error(s), 0 warning(s)", "FAILED, 1 error(s)", "api.json:1:2: error: Missing version", "Watching specs")
+	if e.code != exitValidation {
+		t.Errorf("Expected exit code %d after a failed validation, got %d", exitValidation, e.code)
+	}
+	found := false
+	for _, a := range e.fb.assets {
+		if a.Name == "api.json" {
+			found = true
+			if string(e.fb.content[a.ID]) != `{"title": "My new API"}` {
+				t.Errorf("Must upload the changed api.json, got %s", e.fb.content[a.ID])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Must upload api.json, got %v", e.fb.assets)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/thingforward/slyft-cli/client"
+)
+
+// Exit codes of slyft, by category of the failure. They are documented
+// in the README and scripts rely on them, so never change their values.
+const (
+	exitFailure     = 1  // any other failure
+	exitUsage       = 2  // wrong arguments or input, also used by mow.cli
+	exitAuth        = 3  // not logged in, or not allowed
+	exitNotFound    = 4  // no such project, asset or job
+	exitConflict    = 5  // the resource exists, or the file was changed locally
+	exitNetwork     = 6  // the server could not be reached
+	exitServer      = 7  // the server failed
+	exitValidation  = 8  // assets or jobs have problems
+	exitIncomplete  = 9  // a job did not finish in time
+	exitUnsupported = 10 // the server does not offer the function
+	exitJobFailed   = 11 // a build or other job failed
+	exitCancelled   = 12 // a job was cancelled or aborted
+)
+
+// Error is a failure with the exit code of its category. Errors that are
+// no *Error are categorized by exitCodeOf.
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func newError(code int, format string, args ...interface{}) error {
+	return &Error{code, errors.New(fmt.Sprintf(format, args...))}
+}
+
+// statusError is an error for an unexpected status of the server.
+func statusError(status int, msg string) error {
+	return &Error{exitCodeOfStatus(status), errors.New(msg)}
+}
+
+// exitCodeOf returns the exit code for the category of err.
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	switch e := err.(type) {
+	case *Error:
+		return e.Code
+	case *client.Error:
+		return exitCodeOfStatus(e.StatusCode)
+	case net.Error:
+		return exitNetwork
+	}
+	switch err {
+	case client.ErrNotLoggedIn:
+		return exitAuth
+	case client.ErrCancelUnsupported:
+		return exitUnsupported
+	}
+	return exitFailure
+}
+
+func exitCodeOfStatus(status int) int {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return exitAuth
+	case status == http.StatusNotFound:
+		return exitNotFound
+	case status == http.StatusConflict:
+		return exitConflict
+	case status == http.StatusUnprocessableEntity:
+		return exitValidation
+	case status >= 500:
+		return exitServer
+	}
+	return exitFailure
+}
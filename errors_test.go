@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/thingforward/slyft-cli/client"
+)
+
+func TestExitCodeOf(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected int
+	}{
+		{nil, 0},
+		{errors.New("boom"), exitFailure},
+		{newError(exitNotFound, "No such project"), exitNotFound},
+		{statusError(401, "Unauthorized"), exitAuth},
+		{statusError(409, "Exists"), exitConflict},
+		{statusError(422, "Invalid"), exitValidation},
+		{statusError(503, "Unavailable"), exitServer},
+		{statusError(400, "Bad request"), exitFailure},
+		{&client.Error{StatusCode: 404}, exitNotFound},
+		{client.ErrNotLoggedIn, exitAuth},
+		{client.ErrCancelUnsupported, exitUnsupported},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, exitNetwork},
+	}
+	for _, c := range cases {
+		if code := exitCodeOf(c.err); code != c.expected {
+			t.Errorf("%v: expected exit code %d, got %d", c.err, c.expected, code)
+		}
+	}
+}
+
+func TestJobExitCodes(t *testing.T) {
+	cases := []struct {
+		kind, status string
+		resultStatus int
+		expected     int
+	}{
+		{"build", "processed", 1, 0},
+		{"build", "queued", 0, exitIncomplete},
+		{"validate", "processed", 2, exitValidation},
+		{"validate", "failed", 0, exitValidation},
+		{"build", "processed", 2, exitJobFailed},
+		{"build", "failed", 0, exitJobFailed},
+		{"docs", "Failed", 0, exitJobFailed},
+		{"validate", "error", 0, exitServer},
+		{"build", "timeout", 0, exitServer},
+		{"build", "cancelled", 0, exitCancelled},
+		{"validate", "aborted", 0, exitCancelled},
+	}
+	for _, c := range cases {
+		j := &Job{ID: 1, Kind: c.kind, Status: c.status}
+		j.Results.ResultStatus = c.resultStatus
+		if code := exitCodeOf(j.err()); code != c.expected {
+			t.Errorf("%s job %s (%d): expected exit code %d, got %d", c.kind, c.status, c.resultStatus, c.expected, code)
+		}
+	}
+}
+
+func TestFileResultsKeepFirst(t *testing.T) {
+	results := forEachFile([]string{"a.json", "b.json", "c.json"}, 1, func(file string) error {
+		switch file {
+		case "b.json":
+			return newError(exitNotFound, "No such project")
+		case "c.json":
+			return newError(exitServer, "Server error")
+		}
+		return nil
+	})
+	if code := exitCodeOf(displayFileResults(results)); code != exitNotFound {
+		t.Errorf("Expected the first failure to win, got exit code %d", code)
+	}
+}
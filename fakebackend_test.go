@@ -0,0 +1,470 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thingforward/slyft-cli/client"
+)
+
+// fakeBackend is an in-process Slyft server for end-to-end tests. It
+// keeps projects, assets and jobs in memory and answers with the status
+// codes of the real server.
+type fakeBackend struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	email    string
+	password string
+	auth     SlyftAuth
+	nextID   int
+	projects map[int]*Project
+	assets   map[int]*Asset
+	content  map[int][]byte
+	jobs     map[int]*Job
+	requests []string
+
+	// chunked uploads, unsupported if chunkSize is 0
+	chunkSize     int64
+	uploads       map[string]*client.Upload
+	uploadContent map[string][]byte
+	// failChunk makes the upload of the chunk with this number (from 1) fail
+	failChunk int
+	// jobResult, if set, is the result of processed jobs
+	jobResult *client.JobResults
+	// holdJobs keeps jobs queued
+	holdJobs bool
+	// noCancel makes cancelling jobs unsupported
+	noCancel bool
+	// jobKinds, if set, are listed and run besides build and validate
+	jobKinds []JobKind
+}
+
+// Request bodies, as the server reads them.
+type (
+	projectParam struct {
+		Project Project `json:"project"`
+	}
+	searchString struct {
+		SearchString string `json:"search_string"`
+	}
+	assetParam struct {
+		Asset struct {
+			Name  string `json:"name"`
+			Asset string `json:"asset"`
+		} `json:"asset"`
+	}
+	assetNameString struct {
+		AssetNameString string `json:"asset_name"`
+	}
+	jobParam struct {
+		Job Job `json:"job"`
+	}
+)
+
+func newFakeBackend() *fakeBackend {
+	fb := &fakeBackend{
+		email:    "foo@bar.boo",
+		password: "secret",
+		auth:     SlyftAuth{AccessToken: "token", Client: "client", Uid: "foo@bar.boo"},
+		projects: make(map[int]*Project),
+		assets:   make(map[int]*Asset),
+		content:  make(map[int][]byte),
+		jobs:     make(map[int]*Job),
+
+		chunkSize:     8192,
+		uploads:       make(map[string]*client.Upload),
+		uploadContent: make(map[string][]byte),
+	}
+	fb.Server = httptest.NewServer(http.HandlerFunc(fb.serve))
+	return fb
+}
+
+func (fb *fakeBackend) id() int {
+	fb.nextID++
+	return fb.nextID
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeErrors(w http.ResponseWriter, status int, msgs ...string) {
+	writeJson(w, status, map[string][]string{"errors": msgs})
+}
+
+func (fb *fakeBackend) serve(w http.ResponseWriter, r *http.Request) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	fb.requests = append(fb.requests, r.Method+" "+r.URL.Path)
+	body, _ := ioutil.ReadAll(r.Body)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if parts[0] == "auth" {
+		fb.serveAuth(w, r, parts[1:], body)
+		return
+	}
+
+	if SlyftAuth(client.AuthFromHeader(r.Header)) != fb.auth {
+		writeErrors(w, http.StatusUnauthorized, "You need to sign in or sign up before continuing.")
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[0] == "v1" && parts[1] == "assets" && r.Method == "GET":
+		writeJson(w, http.StatusOK, fb.assetList(0))
+	case len(parts) == 2 && parts[0] == "v1" && parts[1] == "job_kinds" && r.Method == "GET" && fb.jobKinds != nil:
+		writeJson(w, http.StatusOK, fb.jobKinds)
+	case len(parts) >= 2 && parts[0] == "v1" && parts[1] == "projects":
+		fb.serveProjects(w, r, parts[2:], body)
+	default:
+		writeErrors(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (fb *fakeBackend) serveAuth(w http.ResponseWriter, r *http.Request, parts []string, body []byte) {
+	var creds client.Credentials
+	json.Unmarshal(body, &creds)
+
+	switch {
+	case len(parts) == 1 && parts[0] == "sign_in" && r.Method == "POST":
+		if creds.Email != fb.email || creds.Password != fb.password {
+			writeErrors(w, http.StatusUnauthorized, "Invalid login credentials. Please try again.")
+			return
+		}
+		w.Header().Set("access-token", fb.auth.AccessToken)
+		w.Header().Set("client", fb.auth.Client)
+		w.Header().Set("uid", fb.auth.Uid)
+		writeJson(w, http.StatusOK, map[string]interface{}{"data": map[string]string{"email": fb.email}})
+	case len(parts) == 1 && parts[0] == "sign_out" && r.Method == "DELETE":
+		writeJson(w, http.StatusOK, map[string]bool{"success": true})
+	default:
+		writeErrors(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (fb *fakeBackend) projectList(portion string) []Project {
+	projects := make([]Project, 0)
+	for id := 1; id <= fb.nextID; id++ {
+		if p, ok := fb.projects[id]; ok && strings.Contains(p.Name, portion) {
+			projects = append(projects, *p)
+		}
+	}
+	return projects
+}
+
+func (fb *fakeBackend) assetList(projectID int) []Asset {
+	assets := make([]Asset, 0)
+	for id := 1; id <= fb.nextID; id++ {
+		if a, ok := fb.assets[id]; ok && (projectID == 0 || a.ProjectId == projectID) {
+			assets = append(assets, *a)
+		}
+	}
+	return assets
+}
+
+func (fb *fakeBackend) jobList(projectID int) []Job {
+	jobs := make([]Job, 0)
+	for id := 1; id <= fb.nextID; id++ {
+		if j, ok := fb.jobs[id]; ok && j.ProjectId == projectID {
+			jobs = append(jobs, *j)
+		}
+	}
+	return jobs
+}
+
+func (fb *fakeBackend) serveProjects(w http.ResponseWriter, r *http.Request, parts []string, body []byte) {
+	now := time.Now().UTC()
+
+	if len(parts) == 0 {
+		switch r.Method {
+		case "GET":
+			writeJson(w, http.StatusOK, fb.projectList(""))
+		case "POST":
+			var param projectParam
+			json.Unmarshal(body, &param)
+			if param.Project.Name == "" {
+				writeErrors(w, http.StatusUnprocessableEntity, "Name can't be blank")
+				return
+			}
+			for _, p := range fb.projects {
+				if p.Name == param.Project.Name {
+					writeErrors(w, http.StatusConflict, "Name has already been taken")
+					return
+				}
+			}
+			p := param.Project
+			p.ID, p.UserID, p.CreatedAt, p.UpdatedAt = fb.id(), 1, now, now
+			fb.projects[p.ID] = &p
+			writeJson(w, http.StatusCreated, p)
+		default:
+			writeErrors(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	if parts[0] == "search" && r.Method == "GET" {
+		var search searchString
+		json.Unmarshal(body, &search)
+		writeJson(w, http.StatusOK, fb.projectList(search.SearchString))
+		return
+	}
+
+	id, _ := strconv.Atoi(parts[0])
+	p, ok := fb.projects[id]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case "GET":
+			writeJson(w, http.StatusOK, p)
+		case "PUT":
+			var param projectParam
+			json.Unmarshal(body, &param)
+			p.Settings, p.UpdatedAt = param.Project.Settings, now
+			w.WriteHeader(http.StatusNoContent)
+		case "DELETE":
+			delete(fb.projects, id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeErrors(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	switch parts[1] {
+	case "assets":
+		fb.serveAssets(w, r, p, parts[2:], body)
+	case "assetstore":
+		var name assetNameString
+		json.Unmarshal(body, &name)
+		for _, a := range fb.assetList(p.ID) {
+			if a.Name == name.AssetNameString {
+				w.Write(fb.content[a.ID])
+				return
+			}
+		}
+		writeErrors(w, http.StatusNotFound, "Asset not found")
+	case "jobs":
+		fb.serveJobs(w, r, p, parts[2:], body)
+	case "uploads":
+		fb.serveUploads(w, r, p, parts[2:], body)
+	default:
+		writeErrors(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// decodeDataURI returns the content of a data:<mime>;base64,<data> URI.
+func decodeDataURI(uri string) ([]byte, bool) {
+	i := strings.Index(uri, ";base64,")
+	if !strings.HasPrefix(uri, "data:") || i < 0 {
+		return nil, false
+	}
+	content, err := base64.StdEncoding.DecodeString(uri[i+len(";base64,"):])
+	return content, err == nil
+}
+
+func (fb *fakeBackend) serveAssets(w http.ResponseWriter, r *http.Request, p *Project, parts []string, body []byte) {
+	now := time.Now().UTC()
+	var param assetParam
+	json.Unmarshal(body, &param)
+
+	if len(parts) == 0 {
+		switch r.Method {
+		case "GET":
+			writeJson(w, http.StatusOK, fb.assetList(p.ID))
+		case "POST":
+			content, ok := decodeDataURI(param.Asset.Asset)
+			if !ok {
+				writeErrors(w, http.StatusUnprocessableEntity, "Asset is invalid")
+				return
+			}
+			for _, a := range fb.assetList(p.ID) {
+				if a.Name == param.Asset.Name {
+					// duplicates are answered with the existing asset
+					writeJson(w, http.StatusConflict, a)
+					return
+				}
+			}
+			a := &Asset{ID: fb.id(), Name: param.Asset.Name, ProjectId: p.ID, ProjectName: p.Name,
+				Origin: "upload", CreatedAt: now, UpdatedAt: now}
+			fb.assets[a.ID] = a
+			fb.content[a.ID] = content
+			writeJson(w, http.StatusCreated, a)
+		default:
+			writeErrors(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	id, _ := strconv.Atoi(parts[0])
+	a, ok := fb.assets[id]
+	if !ok || a.ProjectId != p.ID {
+		writeErrors(w, http.StatusNotFound, "Asset not found")
+		return
+	}
+	switch r.Method {
+	case "GET":
+		writeJson(w, http.StatusOK, a)
+	case "PUT":
+		content, ok := decodeDataURI(param.Asset.Asset)
+		if !ok {
+			writeErrors(w, http.StatusUnprocessableEntity, "Asset is invalid")
+			return
+		}
+		fb.content[id], a.UpdatedAt = content, now
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		delete(fb.assets, id)
+		delete(fb.content, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// serveJobs creates jobs as "queued"; they are "processed" the next
+// time they are fetched.
+func (fb *fakeBackend) serveJobs(w http.ResponseWriter, r *http.Request, p *Project, parts []string, body []byte) {
+	now := time.Now().UTC()
+
+	if len(parts) == 0 {
+		switch r.Method {
+		case "GET":
+			writeJson(w, http.StatusOK, fb.jobList(p.ID))
+		case "POST":
+			var param jobParam
+			json.Unmarshal(body, &param)
+			if param.Job.Kind != "build" && param.Job.Kind != "validate" && findJobKind(fb.jobKinds, param.Job.Kind) == nil {
+				writeErrors(w, http.StatusUnprocessableEntity, "Kind is not included in the list")
+				return
+			}
+			j := &Job{ID: fb.id(), Kind: param.Job.Kind, Params: param.Job.Params, Status: "queued",
+				ProjectId: p.ID, ProjectName: p.Name, CreatedAt: now, UpdatedAt: now}
+			fb.jobs[j.ID] = j
+			writeJson(w, http.StatusCreated, j)
+		default:
+			writeErrors(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	id, _ := strconv.Atoi(parts[0])
+	j, ok := fb.jobs[id]
+	if !ok || j.ProjectId != p.ID {
+		writeErrors(w, http.StatusNotFound, "Job not found")
+		return
+	}
+	if len(parts) == 2 && parts[1] == "cancel" && r.Method == "POST" && !fb.noCancel {
+		if j.Status != "queued" {
+			writeErrors(w, http.StatusConflict, "Job is already "+j.Status)
+			return
+		}
+		j.Status, j.UpdatedAt = "cancelled", now
+		writeJson(w, http.StatusOK, j)
+		return
+	}
+	if len(parts) > 1 || r.Method != "GET" {
+		writeErrors(w, http.StatusNotFound, "Not found")
+		return
+	}
+	writeJson(w, http.StatusOK, j)
+	if j.Status == "queued" && !fb.holdJobs {
+		j.Status, j.UpdatedAt = "processed", now
+		j.Results = client.JobResults{ResultMessage: "OK", ResultStatus: 1}
+		if fb.jobResult != nil {
+			j.Results = *fb.jobResult
+		} else if j.Kind == "build" {
+			j.Results.ResultAssets = []string{"generated.zip"}
+		}
+	}
+}
+
+// serveUploads implements chunked uploads: chunks must be sent in order,
+// and the content must match size and digest to be completed.
+func (fb *fakeBackend) serveUploads(w http.ResponseWriter, r *http.Request, p *Project, parts []string, body []byte) {
+	if fb.chunkSize == 0 {
+		writeErrors(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if len(parts) == 0 && r.Method == "POST" {
+		var param struct {
+			Upload client.Upload `json:"upload"`
+		}
+		json.Unmarshal(body, &param)
+		u := param.Upload
+		u.ID, u.ChunkSize, u.Offset = fmt.Sprintf("u%d", fb.id()), fb.chunkSize, 0
+		fb.uploads[u.ID] = &u
+		writeJson(w, http.StatusCreated, u)
+		return
+	}
+
+	u, ok := fb.uploads[parts[0]]
+	if !ok || len(parts) > 2 {
+		writeErrors(w, http.StatusNotFound, "Upload not found")
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == "GET":
+		writeJson(w, http.StatusOK, u)
+	case len(parts) == 1 && r.Method == "PUT":
+		var start, end, size int64
+		fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &size)
+		if start != u.Offset || end-start+1 != int64(len(body)) || size != u.Size {
+			writeJson(w, http.StatusConflict, u)
+			return
+		}
+		if fb.failChunk--; fb.failChunk == 0 {
+			writeErrors(w, http.StatusInternalServerError, "Chunk got lost")
+			return
+		}
+		fb.uploadContent[u.ID] = append(fb.uploadContent[u.ID], body...)
+		u.Offset += int64(len(body))
+		writeJson(w, http.StatusOK, u)
+	case len(parts) == 2 && parts[1] == "complete" && r.Method == "POST":
+		content := fb.uploadContent[u.ID]
+		sum := sha256.Sum256(content)
+		if u.Offset != u.Size || hex.EncodeToString(sum[:]) != u.Digest {
+			writeErrors(w, http.StatusUnprocessableEntity, "Upload is incomplete")
+			return
+		}
+		now := time.Now().UTC()
+		if a, ok := fb.assets[u.AssetID]; ok && a.ProjectId == p.ID {
+			fb.content[a.ID], a.UpdatedAt = content, now
+			delete(fb.uploads, u.ID)
+			writeJson(w, http.StatusOK, a)
+			return
+		}
+		for _, a := range fb.assetList(p.ID) {
+			if a.Name == u.Name {
+				writeJson(w, http.StatusConflict, a)
+				return
+			}
+		}
+		a := &Asset{ID: fb.id(), Name: u.Name, ProjectId: p.ID, ProjectName: p.Name,
+			Origin: "upload", CreatedAt: now, UpdatedAt: now}
+		fb.assets[a.ID], fb.content[a.ID] = a, content
+		delete(fb.uploads, u.ID)
+		writeJson(w, http.StatusCreated, a)
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	cli "github.com/jawher/mow.cli"
+	"github.com/thingforward/slyft-cli/client"
+)
+
+// JobKind is a kind of job the server runs, e.g. build or a code
+// generation target.
+type JobKind = client.JobKind
+
+// builtinJobKinds are run by every server.
+var builtinJobKinds = []JobKind{
+	{Name: "build", Description: "Generate code from the assets of a project"},
+	{Name: "validate", Description: "Check the assets of a project"},
+}
+
+// getJobKinds returns the job kinds offered by the server. If the server
+// can't tell, the built-in kinds and the "job_kinds" of ~/.slyftrc are
+// returned, and fromServer is false.
+func getJobKinds() (kinds []JobKind, fromServer bool) {
+	// without login, the kinds are still listed, from the config
+	if auth, err := readAuthFromConfig(); err == nil && auth.GoodForLogin() {
+		kinds, fromServer = fetchJobKinds(auth)
+	}
+	if fromServer {
+		return sortedJobKinds(kinds), true
+	}
+
+	kinds = append([]JobKind{}, builtinJobKinds...)
+	if sr, err := readConfig(); err == nil {
+		for _, k := range sr.JobKinds {
+			if k.Name != "" && findJobKind(kinds, k.Name) == nil {
+				kinds = append(kinds, k)
+			}
+		}
+	}
+	return sortedJobKinds(kinds), false
+}
+
+func fetchJobKinds(auth *SlyftAuth) (kinds []JobKind, ok bool) {
+	kinds, err := newClient(auth).ListJobKinds()
+	if err != nil || len(kinds) == 0 {
+		Log.Debugf("Unable to get job kinds of the server: %v", err)
+		return nil, false
+	}
+	return kinds, true
+}
+
+func sortedJobKinds(kinds []JobKind) []JobKind {
+	sort.Slice(kinds, func(a, b int) bool { return kinds[a].Name < kinds[b].Name })
+	return kinds
+}
+
+func findJobKind(kinds []JobKind, name string) *JobKind {
+	for i := range kinds {
+		if strings.EqualFold(kinds[i].Name, name) {
+			return &kinds[i]
+		}
+	}
+	return nil
+}
+
+func jobKindNames(kinds []JobKind) []string {
+	names := make([]string, len(kinds))
+	for i, k := range kinds {
+		names[i] = k.Name
+	}
+	return names
+}
+
+// parseJobParams reads the parameters of a job from file (JSON or YAML,
+// if not empty) and the key=value pairs of --param, which take
+// precedence. Values of --param are strings, use the file for others.
+func parseJobParams(pairs []string, file string) (map[string]interface{}, error) {
+	params := make(map[string]interface{})
+	if file != "" {
+		content, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		// YAML is a superset of JSON, so this reads both
+		if err := yaml.Unmarshal(content, &params); err != nil {
+			return nil, newError(exitUsage, "Unable to read parameters from %s: %v", file, err)
+		}
+	}
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || key == "" {
+			return nil, newError(exitUsage, "Invalid parameter %s, use key=value", pair)
+		}
+		params[key] = kv[1]
+	}
+	if len(params) == 0 {
+		return nil, nil
+	}
+	return params, nil
+}
+
+func displayJobKinds(kinds []JobKind, fromServer bool) {
+	if structuredOutput() {
+		displayStructured(kinds)
+		return
+	}
+	data := [][]string{{"Kind", "Description"}}
+	for _, k := range kinds {
+		data = append(data, []string{k.Name, k.Description})
+	}
+	fmt.Fprintf(os.Stdout, "%s", markdownTable(&data))
+	if !fromServer {
+		fmt.Println("The server does not list its job kinds; add others to \"job_kinds\" in ~/.slyftrc")
+	}
+}
+
+func listJobKinds(cmd *cli.Cmd) {
+	cmd.Action = action(func() error {
+		displayJobKinds(getJobKinds())
+		return nil
+	})
+}
+
+func runJob(cmd *cli.Cmd) {
+	cmd.Spec = "[--project] [--param]... [--params-file] [--wait] [--follow] KIND"
+	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	pairs := cmd.StringsOpt("param P", nil, "Parameter of the job as key=value, may be repeated")
+	paramsFile := cmd.StringOpt("params-file", "", "JSON or YAML file with the parameters of the job")
+	wait := cmd.IntOpt("wait w", 0, "Optional number of seconds to wait for job completion")
+	follow := cmd.BoolOpt("follow F", false, "Wait for the job to finish, printing its status (default: false)")
+	kind := cmd.StringArg("KIND", "", "Kind of the job, see `slyft job kinds`")
+
+	cmd.Action = action(func() error {
+		params, err := parseJobParams(*pairs, *paramsFile)
+		if err != nil {
+			return ReportError("Reading the parameters", err)
+		}
+		// only the server knows all kinds, others are left for it to reject
+		kinds, fromServer := getJobKinds()
+		if k := findJobKind(kinds, *kind); k != nil {
+			*kind = k.Name
+		} else if fromServer {
+			return ReportError("Choosing the kind", newError(exitUsage, "Unknown job kind %s, choose one of %s", *kind, strings.Join(jobKindNames(kinds), ", ")))
+		} else {
+			Log.Debugf("Job kind %s is not known, trying anyway", *kind)
+		}
+
+		p, err := jobProject(*name)
+		if err != nil {
+			return ReportError("Choosing the project", err)
+		}
+		following := *follow || *wait > 0
+		job, err := startJob(*kind, params, p, following)
+		if err != nil || !following {
+			return err
+		}
+		return awaitJob(job, *wait, "", "")
+	})
+}
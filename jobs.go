@@ -1,33 +1,26 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	cli "github.com/jawher/mow.cli"
+	"github.com/thingforward/slyft-cli/client"
 )
 
-type Job struct {
-	ID          int        `json:"id"`
-	Kind        string     `json:"kind"`
-	Status      string     `json:"status"`
-	Results     JobResults `json:"results"`
-	ProjectId   int        `json:"project_id"`
-	ProjectName string     `json:"project_name"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-}
+// Job is a client.Job as shown and followed by the commands.
+type Job client.Job
 
-type JobResults struct {
-	ResultMessage string   `json:"resultMessage"`
-	ResultStatus  int      `json:"resultStatus"`
-	ResultAssets  []string `json:"resultAssets"`
-	ResultDetails []string `json:"resultDetails"`
+// toJobs converts the jobs returned by the client.
+func toJobs(jobs []client.Job) []Job {
+	converted := make([]Job, len(jobs))
+	for i := range jobs {
+		converted[i] = Job(jobs[i])
+	}
+	return converted
 }
 
 func (j *Job) Display() { // String?
@@ -35,6 +28,11 @@ func (j *Job) Display() { // String?
 		return
 	}
 
+	if structuredOutput() {
+		displayStructured(j)
+		return
+	}
+
 	data := [][]string{
 		[]string{"Key", "Value"},
 		[]string{"Id", fmt.Sprintf("%d", j.ID)},
@@ -47,20 +45,32 @@ func (j *Job) Display() { // String?
 		[]string{"ResultMessage", j.Results.ResultMessage},
 		[]string{"ResultStatus", fmt.Sprintf("%d", j.Results.ResultStatus)}}
 
+	keys := make([]string, 0, len(j.Params))
+	for key := range j.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		data = append(data, []string{fmt.Sprintf("Params[%s]", key), fmt.Sprint(j.Params[key])})
+	}
+
 	for index, asset := range j.Results.ResultAssets {
 		data = append(data, []string{fmt.Sprintf("ResultAssets[%d]", index), asset})
 	}
-	// Details are an array.
-	for index, detail := range j.Results.ResultDetails {
-		data = append(data, []string{fmt.Sprintf("ResultDetails[%d]", index), detail})
-	}
 
 	fmt.Fprintf(os.Stdout, "%s%s",
 		markdownHeading("Job Details", 1),
 		markdownTable(&data))
+	// Details are shown as diagnostics.
+	displayDiagnostics(j.Diagnostics())
 }
 
 func DisplayJobs(jobs []Job) {
+	if structuredOutput() {
+		displayStructured(jobs)
+		return
+	}
+
 	if len(jobs) == 0 {
 		fmt.Println("No jobs found")
 		return
@@ -80,36 +90,14 @@ func DisplayJobs(jobs []Job) {
 	fmt.Fprint(os.Stdout, markdownTable(&data))
 }
 
-func extractJobsFromBody(body []byte) ([]Job, error) {
-	jobs := make([]Job, 0)
-	if err := json.Unmarshal(body, &jobs); err != nil {
-		return nil, err
-	}
-	return jobs, nil
-}
-
-func extractJobFromBody(body []byte) ([]Job, error) {
-	a := &Job{}
-	if err := json.Unmarshal(body, &a); err != nil {
-		return nil, err
-	}
-	return []Job{*a}, nil
-}
-
-func chooseJob(endpoint string, askUser bool, message string) (*Job, error) {
-	resp, err := Do(endpoint, "GET", nil)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	jobs, err := extractJobFromResponse(resp, http.StatusOK, true)
-
+func chooseJob(p *Project, askUser bool, message string) (*Job, error) {
+	jobs, err := getJobs(p)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(jobs) == 0 {
-		return nil, errors.New("No job. Sorry")
+		return nil, newError(exitNotFound, "No job. Sorry")
 	}
 
 	DisplayJobs(jobs)
@@ -122,159 +110,477 @@ func chooseJob(endpoint string, askUser bool, message string) (*Job, error) {
 		return nil, nil
 	}
 
+	if !interactive() {
+		candidates := make([]string, 0, len(jobs))
+		for _, j := range jobs {
+			candidates = append(candidates, fmt.Sprintf("%d (%s, %s)", j.ID, j.Kind, j.Status))
+		}
+		return nil, ambiguousChoice("job", candidates)
+	}
+
 	choice, err := ReadUserIntInput(message)
 	if err != nil {
 		return nil, err
 	}
 
 	if choice > len(jobs) {
-		return nil, errors.New("Plese choose a number from the first column")
+		return nil, newError(exitUsage, "Plese choose a number from the first column")
 	}
 
 	return &jobs[choice-1], nil
 }
 
-type JobParam struct {
-	Job Job `json:"job"`
-}
-
-func creatJobParam(kind string, p *Project) *JobParam {
-	return &JobParam{
-		Job{
-			Kind:      kind,
-			ProjectId: p.ID,
-		},
-	}
-}
-
-func postNewJob(kind, name string) *Job {
+// postNewJob starts a job of kind for the project matching name. Unless
+// the job is followed, it is displayed or a hint where to find it.
+func postNewJob(kind, name string, follow bool) (*Job, error) {
 	p, err := chooseProject(name, fmt.Sprintf("%s project: ", kind))
 	if err != nil {
-		ReportError("Choosing a project", err)
-		return nil
+		return nil, ReportError("Choosing a project", err)
 	}
+	return startJob(kind, nil, p, follow)
+}
 
-	resp, err := Do(p.JobsUrl(), "POST", creatJobParam(kind, p))
+// startJob is postNewJob for a known project, with the parameters of the
+// job (may be nil).
+func startJob(kind string, params map[string]interface{}, p *Project, follow bool) (*Job, error) {
+	c, err := authClient()
 	if err != nil {
-		ReportError("Contacting the server", err)
-		return nil
+		return nil, ReportError("Contacting the server", err)
 	}
-
-	defer resp.Body.Close()
-	jobs, err := extractJobFromResponse(resp, http.StatusCreated, false)
+	created, err := c.RunJob(p.ID, kind, params)
 	if err != nil {
-		ReportError("Error creating job:", err)
-		return nil
+		return nil, ReportError("Error creating job:", err)
 	}
 
-	Log.Debugf("jobs=%#v", jobs)
-	if len(jobs) == 1 {
-		j := jobs[0]
-		if j.Results.ResultStatus == 0 {
-			fmt.Printf("Job %d is started, use `slyft project status` to view status details\n", j.ID)
-		} else {
-			fmt.Printf("Job %d is completed, use `slyft project status` to view status details\n", j.ID)
-		}
-		return &j
+	Log.Debugf("job=%#v", created)
+	j := (*Job)(created)
+	if follow {
+		return j, nil
+	} else if structuredOutput() {
+		j.Display()
+	} else if j.Results.ResultStatus == 0 {
+		fmt.Printf("Job %d is started, use `slyft project status` to view status details\n", j.ID)
+	} else {
+		fmt.Printf("Job %d is completed, use `slyft project status` to view status details\n", j.ID)
 	}
-
-	Log.Errorf("Error, creating a new job returned wrong job data")
-	return nil
+	return j, nil
 }
 
 func jobStatusProject(cmd *cli.Cmd) {
-	cmd.Spec = "[--project]"
+	cmd.Spec = "[--project] [--report FILE]"
 	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	report := cmd.StringOpt("report", "", "Write the diagnostics of the job to FILE as sarif, junit or codeclimate report")
+	reportFile := cmd.StringArg("FILE", "", "File to write the report to")
 
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
+		if *report != "" {
+			if err := checkReportFormat(*report); err != nil {
+				return ReportError("Choosing the report", err)
+			}
+		}
 		*name = strings.TrimSpace(*name)
 		// if project name not given, try to read project lock file
 		if *name == "" {
 			*name, _ = ReadProjectLock()
 		}
-		var p *Project
-		var err error
 		// still no project known? We need to ask user for specific project
-		if *name == "" {
-			p, err = chooseProject(*name, "Which project's jobs would you like to see: ")
-			if p == nil || err != nil {
-				ReportError("Choosing the project", err)
-				return
-			}
+		p, err := chooseProject(*name, "Which project's jobs would you like to see: ")
+		if p == nil || err != nil {
+			return ReportError("Choosing the project", err)
 		}
 
-		job, err := chooseJob(p.JobsUrl(), true, "Select a job id to show more details: ")
+		job, err := chooseJob(p, true, "Select a job id to show more details: ")
 		if err != nil {
-			ReportError("Selecting the job", err)
-			return
+			return ReportError("Selecting the job", err)
 		}
 		job.Display()
+		if *report != "" {
+			if err := writeJobReport(job, *report, *reportFile); err != nil {
+				return ReportError("Writing the report", err)
+			}
+		}
+		return nil
+	})
+}
+
+// failedJobStates are the states in which a job ended without result.
+var failedJobStates = []string{"failed", "error", "aborted", "cancelled", "canceled", "timeout"}
+
+// Intervals between polls of a followed job. They grow from the first to
+// the maximum, so quick jobs are reported quickly without keeping the
+// server busy with long ones.
+var (
+	followInterval    = 1 * time.Second
+	followMaxInterval = 15 * time.Second
+)
+
+// finished reports whether the job reached a terminal state.
+func (j *Job) finished() bool {
+	return j.Status == "processed" || j.failed()
+}
+
+// failed reports whether the job ended in a failure state, or was
+// processed with a result status other than success (1).
+func (j *Job) failed() bool {
+	for _, s := range failedJobStates {
+		if strings.EqualFold(j.Status, s) {
+			return true
+		}
 	}
+	return j.Status == "processed" && j.Results.ResultStatus > 1
 }
 
-func waitForJobCompletion(job *Job, wait int) bool {
-	fmt.Printf("Waiting (max. %d seconds) for job completion.", wait)
-	for wait > 0 {
-		wait -= 5
-		time.Sleep(5 * time.Second)
-		fmt.Print(".")
+// err returns an error if the job failed or is not finished yet.
+func (j *Job) err() error {
+	switch {
+	case j.failed():
+		return newError(j.failureCode(), "Job %d failed: %s", j.ID, j.Results.ResultMessage)
+	case !j.finished():
+		return newError(exitIncomplete, "Job %d is not finished yet (%s)", j.ID, j.Status)
+	}
+	return nil
+}
 
-		resp, err := Do(job.EndPoint(), "GET", nil)
-		if err != nil {
-			return false
-		}
-		defer resp.Body.Close()
-		jobs, err := extractJobFromResponse(resp, http.StatusOK, false)
-		Log.Debugf("jobs=%#v", jobs)
-		//Log.Debugf("#jobs=%d", len(jobs))
-		//Log.Debugf("err=%#v", err)
-
-		if err == nil && jobs != nil && len(jobs) == 1 {
-			Log.Debugf("status=%s", jobs[0].Status)
-			if jobs[0].Status == "processed" {
-				jobs[0].Display()
-				return true
+// failureCode tells the exit code for a failed job: the server failed
+// to run it, it was cancelled, a validation found problems, or a build
+// or other job failed.
+func (j *Job) failureCode() int {
+	switch strings.ToLower(j.Status) {
+	case "error", "timeout":
+		return exitServer
+	case "aborted", "cancelled", "canceled":
+		return exitCancelled
+	}
+	if strings.EqualFold(j.Kind, "validate") {
+		return exitValidation
+	}
+	return exitJobFailed
+}
+
+// followJob polls job until it is finished or timeout has passed (if it
+// is not 0), printing each change of its status. It returns the last
+// known state of the job.
+func followJob(job *Job, timeout time.Duration) (*Job, error) {
+	out := statusOutput()
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	status := job.Status
+	fmt.Fprintf(out, "%s Job %d is %s\n", time.Now().Format("15:04:05"), job.ID, status)
+	interval := followInterval
+	for !job.finished() {
+		// the last poll is right at the deadline, not an interval before
+		sleep := interval
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				fmt.Fprintf(out, "Job %d did not complete in time. Please check manually using `slyft project status`\n", job.ID)
+				return job, nil
+			}
+			if sleep > remaining {
+				sleep = remaining
 			}
 		}
+		time.Sleep(sleep)
+		if interval *= 2; interval > followMaxInterval {
+			interval = followMaxInterval
+		}
 
+		next, err := fetchJob(job)
+		if err != nil {
+			return job, err
+		}
+		job = next
+		if job.Status != status {
+			status = job.Status
+			fmt.Fprintf(out, "%s Job %d is %s\n", time.Now().Format("15:04:05"), job.ID, status)
+		}
 	}
-	// if we get here, job did not finish in time. Say so.
-	fmt.Printf("Job %d did not complete in time. Please check manually using `slyft project status`", job.ID)
-	return false
+	return job, nil
+}
+
+// awaitJob follows a job until it is finished or wait seconds have
+// passed (if not 0), displays its result, writes the report if reportFile
+// is set, and returns the job's failure, if any.
+func awaitJob(job *Job, wait int, reportFormat, reportFile string) error {
+	job, err := followJob(job, time.Duration(wait)*time.Second)
+	if err != nil {
+		return ReportError("Following the job", err)
+	}
+	if job.finished() {
+		job.Display()
+	}
+	if reportFile != "" {
+		if err := writeJobReport(job, reportFormat, reportFile); err != nil {
+			return ReportError("Writing the report", err)
+		}
+	}
+	return job.err()
 }
 
 func buildProject(cmd *cli.Cmd) {
-	cmd.Spec = "[--project] [--wait]"
+	cmd.Spec = "[--project] [--wait] [--follow]"
 	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
 	wait := cmd.IntOpt("wait w", 0, "Optional number of seconds to wait for job completion")
+	follow := cmd.BoolOpt("follow F", false, "Wait for the job to finish, printing its status (default: false)")
 	if *name == "" {
 		*name, _ = ReadProjectLock()
 	}
 
-	cmd.Action = func() {
-		job := postNewJob("build", strings.TrimSpace(*name))
-		if job != nil && wait != nil && *wait > 0 {
-			waitForJobCompletion(job, *wait)
+	cmd.Action = action(func() error {
+		following := *follow || *wait > 0
+		job, err := postNewJob("build", strings.TrimSpace(*name), following)
+		if err != nil || !following {
+			return err
 		}
-	}
+		return awaitJob(job, *wait, "", "")
+	})
 }
 
 func validateProject(cmd *cli.Cmd) {
-	cmd.Spec = "[--project] [--wait]"
+	cmd.Spec = "[--project] [--wait] [--follow] [--report FILE]"
 	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
 	wait := cmd.IntOpt("wait w", 0, "Optional number of seconds to wait for job completion")
+	follow := cmd.BoolOpt("follow F", false, "Wait for the job to finish, printing its status (default: false)")
+	report := cmd.StringOpt("report", "", "Write the diagnostics to FILE as sarif, junit or codeclimate report (implies --follow)")
+	reportFile := cmd.StringArg("FILE", "", "File to write the report to")
 	if *name == "" {
 		*name, _ = ReadProjectLock()
 	}
 
-	cmd.Action = func() {
-		job := postNewJob("validate", strings.TrimSpace(*name))
-		if job != nil && wait != nil && *wait > 0 {
-			waitForJobCompletion(job, *wait)
+	cmd.Action = action(func() error {
+		if *report != "" {
+			if err := checkReportFormat(*report); err != nil {
+				return ReportError("Choosing the report", err)
+			}
 		}
+		following := *follow || *wait > 0 || *report != ""
+		job, err := postNewJob("validate", strings.TrimSpace(*name), following)
+		if err != nil || !following {
+			return err
+		}
+		return awaitJob(job, *wait, *report, *reportFile)
+	})
+}
+
+// getJob returns the current state of the job with the given ID.
+func getJob(p *Project, id int) (*Job, error) {
+	return fetchJob(&Job{ID: id, ProjectId: p.ID})
+}
+
+// fetchJob returns the current state of job.
+func fetchJob(job *Job) (*Job, error) {
+	c, err := authClient()
+	if err != nil {
+		return nil, err
+	}
+	current, err := c.GetJob(job.ProjectId, job.ID)
+	return (*Job)(current), err
+}
+
+// getJobs returns all jobs of p.
+func getJobs(p *Project) ([]Job, error) {
+	c, err := authClient()
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := c.ListJobs(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	return toJobs(jobs), nil
+}
+
+// jobProject returns the project matching name, or the one remembered
+// in the current directory.
+func jobProject(name string) (*Project, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name, _ = ReadProjectLock()
+	}
+	return chooseProject(name, "Which project's jobs: ")
+}
+
+// parseSince parses the --since option of `slyft job list`, either a
+// duration before now (e.g. 36h) or a date (2006-01-02) or time (RFC 3339).
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", since, time.Local); err == nil {
+		return t, nil
 	}
+	return time.Time{}, newError(exitUsage, "Unable to parse --since %s, use a duration like 24h, or a date like 2006-01-02", since)
 }
 
-func (job *Job) EndPoint() string {
-	return fmt.Sprintf("/v1/projects/%d/jobs/%d", job.ProjectId, job.ID)
+// filterJobs returns the jobs of the given kind and status (if not
+// empty) created after since, newest first, at most limit (if not 0).
+func filterJobs(jobs []Job, kind, status string, since time.Time, limit int) []Job {
+	filtered := make([]Job, 0, len(jobs))
+	for _, j := range jobs {
+		if kind != "" && !strings.EqualFold(j.Kind, kind) ||
+			status != "" && !strings.EqualFold(j.Status, status) ||
+			j.CreatedAt.Before(since) {
+			continue
+		}
+		filtered = append(filtered, j)
+	}
+	sort.SliceStable(filtered, func(a, b int) bool {
+		if filtered[a].CreatedAt.Equal(filtered[b].CreatedAt) {
+			return filtered[a].ID > filtered[b].ID
+		}
+		return filtered[a].CreatedAt.After(filtered[b].CreatedAt)
+	})
+	if limit > 0 && len(filtered) > limit {
+		filtered = filtered[:limit]
+	}
+	return filtered
+}
+
+func listJobs(cmd *cli.Cmd) {
+	cmd.Spec = "[--project] [--kind] [--status] [--since] [--limit]"
+	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	kind := cmd.StringOpt("kind k", "", "Only list jobs of this kind, e.g. build or validate")
+	status := cmd.StringOpt("status s", "", "Only list jobs with this status, e.g. queued or processed")
+	since := cmd.StringOpt("since", "", "Only list jobs created after this duration ago (e.g. 24h) or date (e.g. 2006-01-02)")
+	limit := cmd.IntOpt("limit n", 0, "List at most this many jobs, newest first")
+
+	cmd.Action = action(func() error {
+		var after time.Time
+		if *since != "" {
+			var err error
+			if after, err = parseSince(*since, time.Now()); err != nil {
+				return ReportError("Listing the jobs", err)
+			}
+		}
+		p, err := jobProject(*name)
+		if err != nil {
+			return ReportError("Choosing the project", err)
+		}
+		jobs, err := getJobs(p)
+		if err != nil {
+			return ReportError("Listing the jobs", err)
+		}
+		DisplayJobs(filterJobs(jobs, *kind, *status, after, *limit))
+		return nil
+	})
+}
+
+func showJob(cmd *cli.Cmd) {
+	cmd.Spec = "[--project] ID"
+	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	id := cmd.IntArg("ID", 0, "ID of the job")
+
+	cmd.Action = action(func() error {
+		p, err := jobProject(*name)
+		if err != nil {
+			return ReportError("Choosing the project", err)
+		}
+		job, err := getJob(p, *id)
+		if err != nil {
+			return ReportError("Showing the job", err)
+		}
+		job.Display()
+		return nil
+	})
+}
+
+func waitJob(cmd *cli.Cmd) {
+	cmd.Spec = "[--project] [--timeout] ID"
+	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	timeout := cmd.IntOpt("timeout t", 0, "Number of seconds to wait at most (default: no limit)")
+	id := cmd.IntArg("ID", 0, "ID of the job")
+
+	cmd.Action = action(func() error {
+		p, err := jobProject(*name)
+		if err != nil {
+			return ReportError("Choosing the project", err)
+		}
+		job, err := getJob(p, *id)
+		if err != nil {
+			return ReportError("Waiting for the job", err)
+		}
+		return awaitJob(job, *timeout, "", "")
+	})
+}
+
+func cancelJob(cmd *cli.Cmd) {
+	cmd.Spec = "[--project] ID"
+	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	id := cmd.IntArg("ID", 0, "ID of the job")
+
+	cmd.Action = action(func() error {
+		p, err := jobProject(*name)
+		if err != nil {
+			return ReportError("Choosing the project", err)
+		}
+		job, err := getJob(p, *id)
+		if err != nil {
+			return ReportError("Cancelling the job", err)
+		}
+		if job.finished() {
+			return ReportError("Cancelling the job", newError(exitConflict, "Job %d is already %s", job.ID, job.Status))
+		}
+
+		c, err := authClient()
+		if err != nil {
+			return ReportError("Cancelling the job", err)
+		}
+		cancelled, err := c.CancelJob(p.ID, job.ID)
+		if err != nil {
+			return ReportError("Cancelling the job", err)
+		}
+		job = (*Job)(cancelled)
+		if structuredOutput() {
+			job.Display()
+			return nil
+		}
+		fmt.Printf("Job %d is %s\n", job.ID, job.Status)
+		return nil
+	})
+}
+
+func retryJob(cmd *cli.Cmd) {
+	cmd.Spec = "[--project] [--follow] ID"
+	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	follow := cmd.BoolOpt("follow F", false, "Wait for the new job to finish, printing its status (default: false)")
+	id := cmd.IntArg("ID", 0, "ID of the job")
+
+	cmd.Action = action(func() error {
+		p, err := jobProject(*name)
+		if err != nil {
+			return ReportError("Choosing the project", err)
+		}
+		job, err := getJob(p, *id)
+		if err != nil {
+			return ReportError("Retrying the job", err)
+		}
+		if !job.finished() {
+			return ReportError("Retrying the job", newError(exitConflict, "Job %d is still %s, wait for it or cancel it first", job.ID, job.Status))
+		}
+
+		// a retry is a new job of the same kind and parameters
+		retry, err := startJob(job.Kind, job.Params, p, *follow)
+		if err != nil || !*follow {
+			return err
+		}
+		return awaitJob(retry, 0, "", "")
+	})
+}
+
+func RegisterJobRoutes(job *cli.Cmd) {
+	SetupLogger()
+
+	job.Command("list ls", "List the jobs of a project", listJobs)
+	job.Command("show sh", "Show a job", showJob)
+	job.Command("wait w", "Wait for a job to finish", waitJob)
+	job.Command("cancel", "Cancel a queued or running job", cancelJob)
+	job.Command("retry", "Start a finished job again", retryJob)
+	job.Command("run", "Start a job of any kind, with parameters", runJob)
+	job.Command("kinds", "List the kinds of jobs the server runs", listJobKinds)
 }
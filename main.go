@@ -19,10 +19,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/jawher/mow.cli"
 	"github.com/op/go-logging"
+	"github.com/thingforward/slyft-cli/client"
 )
 
 var VERSION = "0.3.3"
@@ -86,7 +88,7 @@ func showBanner() {
 }
 
 func showInfo(cmd *cli.Cmd) {
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
 		showBanner()
 		fmt.Printf(`
 slyft, slyft.io is (C)opright 2017 Digital Incubation and Growth GmbH
@@ -115,29 +117,66 @@ Contains libraries from
 * https://github.com/op/go-logging		Copyright (c) 2013 Örjan Persson
 * https://github.com/ghodss/yaml                Copyright (c) 2014 Sam Ghods
 `)
-	}
+		return nil
+	})
 }
 
 func main() {
 	if len(os.Args) <= 1 {
 		showBanner()
 	}
-	err := UpdateCheck(VERSION)
-	if err != nil {
-		Log.Error(err)
-		return
+
+	newApp().Run(os.Args)
+}
+
+// action adapts a command to mow.cli. Commands report their failures and
+// return them, and slyft exits with the code of the failure's category.
+func action(run func() error) func() {
+	return func() {
+		if err := run(); err != nil {
+			exit(exitCodeOf(err))
+		}
 	}
+}
 
+// newApp sets up the command line interface with all global options and
+// commands.
+func newApp() *cli.Cli {
 	app := cli.App("slyft", "")
 
 	fDebug = app.BoolOpt("debug d", false, "Show debug output")
+	fNonInteractive = app.BoolOpt("non-interactive", false, "Never prompt, fail on ambiguous choices (default if stdin is no terminal)")
+	fYes = app.BoolOpt("yes y", false, "Answer all confirmations with yes, implies --non-interactive")
+	fRetries = app.Int(cli.IntOpt{
+		Name:   "retries",
+		Value:  client.DefaultRetryPolicy.Attempts - 1,
+		Desc:   "Number of retries on transient network or server errors",
+		EnvVar: "SLYFT_RETRIES",
+	})
+	fProfile = app.String(cli.StringOpt{
+		Name:   "profile",
+		Value:  "",
+		Desc:   "Name of the profile (backend and credentials) to use",
+		EnvVar: "SLYFT_PROFILE",
+	})
+	fOutput = app.StringOpt("output o", outputMarkdown, "Output format for listings and details: markdown, json or yaml")
 
 	app.Version("v version", VERSION)
 
+	app.Before = func() {
+		if err := validateOutputFormat(outputFormat()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(exitUsage)
+		}
+		backendChosen = selectProfileBackend()
+		connectOnce = new(sync.Once)
+	}
+
 	app.Command("user u", "User/Account management", RegisterUserRoutes)
 	app.Command("project p", "Project management", RegisterProjectRoutes)
 	app.Command("asset a", "Asset management", RegisterAssetRoutes)
+	app.Command("job j", "Job management", RegisterJobRoutes)
 	app.Command("info", "Show program info", showInfo)
 
-	app.Run(os.Args)
+	return app
 }
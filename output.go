@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ghodss/yaml"
+)
+
+const (
+	outputMarkdown = "markdown"
+	outputJson     = "json"
+	outputYaml     = "yaml"
+)
+
+var fOutput *string
+
+func outputFormat() string {
+	if fOutput == nil || *fOutput == "" {
+		return outputMarkdown
+	}
+	return *fOutput
+}
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputMarkdown, outputJson, outputYaml:
+		return nil
+	}
+	return newError(exitUsage, "Unknown output format '%s', use one of markdown, json, yaml", format)
+}
+
+// structuredOutput reports whether listings and details are to be
+// printed as JSON or YAML instead of markdown tables.
+func structuredOutput() bool {
+	return outputFormat() != outputMarkdown
+}
+
+// formatStructured serializes v as JSON or YAML. Field names are taken
+// from the json struct tags in both cases.
+func formatStructured(v interface{}, format string) ([]byte, error) {
+	if format == outputYaml {
+		return yaml.Marshal(v)
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// displayStructured prints v in the selected machine-readable format.
+func displayStructured(v interface{}) {
+	out, err := formatStructured(v, outputFormat())
+	if err != nil {
+		ReportError("Formatting output", err)
+		return
+	}
+	os.Stdout.Write(out)
+}
+
+// statusOutput is where progress and status messages go: stdout, or
+// stderr with --output json or yaml, so stdout only holds the document.
+func statusOutput() io.Writer {
+	if structuredOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// statusf prints a progress or status message to statusOutput.
+func statusf(format string, args ...interface{}) {
+	fmt.Fprintf(statusOutput(), format, args...)
+}
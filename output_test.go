@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatStructured(t *testing.T) {
+	projects := []Project{{ID: 1, Name: "TestName", Details: "TestDetails"}}
+
+	out, err := formatStructured(projects, outputJson)
+	if err != nil {
+		t.Fatalf("Must format JSON: %v", err)
+	}
+	if !strings.HasPrefix(string(out), "[") || !strings.Contains(string(out), `"name": "TestName"`) {
+		t.Errorf("Unexpected JSON output:\n%s", out)
+	}
+
+	out, err = formatStructured(projects, outputYaml)
+	if err != nil {
+		t.Fatalf("Must format YAML: %v", err)
+	}
+	if !strings.HasPrefix(string(out), "- ") || !strings.Contains(string(out), "name: TestName") {
+		t.Errorf("Unexpected YAML output:\n%s", out)
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, f := range []string{outputMarkdown, outputJson, outputYaml} {
+		if validateOutputFormat(f) != nil {
+			t.Errorf("Must accept output format %s", f)
+		}
+	}
+	if validateOutputFormat("xml") == nil {
+		t.Error("Must reject unknown output format")
+	}
+}
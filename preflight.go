@@ -3,68 +3,438 @@ package main
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"github.com/ghodss/yaml"
+	"os"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"unicode/utf8"
+
+	"github.com/ghodss/yaml"
 )
 
 const maxAssetLen int = 20000
 
-func preflightAsset(a *[]byte, file string) (string, error) {
-	if len(*a) == 0 {
-		return "", errors.New("input must not be empty")
+const (
+	contentTypeJSON = "application/json"
+	contentTypeYAML = "application/x-yaml"
+	contentTypeRAML = "application/raml+yaml"
+)
+
+// Problem is something wrong with an asset found by preflight. Line and
+// Column start at 1 and are 0 if unknown.
+type Problem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	switch {
+	case p.Line > 0 && p.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+	case p.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
 	}
+	return fmt.Sprintf("%s: %s", p.File, p.Message)
+}
 
-	if len(*a) > maxAssetLen {
-		return "", errors.New(fmt.Sprintf("input length must not exceed %d", maxAssetLen))
+// Validator checks a kind of document beyond being well-formed JSON,
+// YAML or RAML, e.g. an OpenAPI specification.
+type Validator interface {
+	Name() string
+	// Accepts reports whether doc is of the kind checked by the validator.
+	Accepts(doc *assetDocument) bool
+	Validate(doc *assetDocument) []Problem
+}
+
+// validators maps content types to the validators that may apply to
+// documents of that type.
+var validators = map[string][]Validator{
+	contentTypeJSON: {openAPIValidator{}, jsonSchemaValidator{}},
+	contentTypeYAML: {openAPIValidator{}, jsonSchemaValidator{}},
+	contentTypeRAML: {ramlValidator{}},
+}
+
+var (
+	reYaml = regexp.MustCompile(`(?i)\.ya?ml$`) //'a' is optional
+	reRaml = regexp.MustCompile(`(?i)\.raml$`)  //'a' is obligatory
+)
+
+// assetContentType tells the content type of an asset by its extension,
+// assuming JSON for everything else.
+func assetContentType(file string) string {
+	switch {
+	case reRaml.MatchString(file):
+		return contentTypeRAML
+	case reYaml.MatchString(file):
+		return contentTypeYAML
+	}
+	return contentTypeJSON
+}
+
+// assetDocument is an asset parsed for validation. Value holds the
+// content decoded like JSON, i.e. objects are map[string]interface{}.
+type assetDocument struct {
+	File        string
+	ContentType string
+	Content     []byte
+	Value       interface{}
+}
+
+// object returns the top level object of the document, if it is one.
+func (d *assetDocument) object() (map[string]interface{}, bool) {
+	m, ok := d.Value.(map[string]interface{})
+	return m, ok
+}
+
+// position converts a byte offset into line and column.
+func (d *assetDocument) position(offset int) (int, int) {
+	if offset > len(d.Content) {
+		offset = len(d.Content)
+	}
+	before := d.Content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+	return line, column
+}
+
+// locate returns the position of the key at path, or of the closest of
+// its ancestors found. The keys are searched one after another in the
+// source, which is good enough for both one-key-per-line and minified
+// documents. The root of the document is at its start.
+func (d *assetDocument) locate(path []string) (int, int) {
+	if len(path) == 0 {
+		return 1, 1
+	}
+	offset, found := 0, -1
+	for _, key := range path {
+		if isIndex(key) {
+			if item := d.arrayItem(offset, key); item >= 0 {
+				found, offset = item, item
+			}
+			continue
+		}
+		re := regexp.MustCompile(`(?m)(?:^|[\s{,])(["']?` + regexp.QuoteMeta(key) + `["']?\s*:)`)
+		loc := re.FindSubmatchIndex(d.Content[offset:])
+		if loc == nil {
+			break
+		}
+		found = offset + loc[2]
+		offset += loc[3]
+	}
+	if found < 0 {
+		return 0, 0
+	}
+	return d.position(found)
+}
+
+// arrayItem returns the offset of the array item at index, searching
+// from offset. Items are recognized if each starts a line, like in YAML
+// block sequences ("- ") and pretty-printed JSON arrays of objects ("{").
+func (d *assetDocument) arrayItem(offset int, index string) int {
+	n, err := strconv.Atoi(strings.Trim(index, "[]"))
+	if err != nil {
+		return -1
+	}
+	marker, indent := "", -1
+	for pos := offset; pos < len(d.Content); {
+		end := bytes.IndexByte(d.Content[pos:], '\n')
+		if end < 0 {
+			end = len(d.Content) - pos
+		}
+		line := string(d.Content[pos : pos+end])
+		trimmed := strings.TrimLeft(line, " \t")
+		lineIndent := len(line) - len(trimmed)
+		if marker == "" && (strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "{")) {
+			marker, indent = trimmed[:1], lineIndent
+		}
+		if marker != "" {
+			if trimmed != "" && lineIndent < indent {
+				return -1
+			}
+			if lineIndent == indent && strings.HasPrefix(trimmed, marker) {
+				if n == 0 {
+					return pos + lineIndent
+				}
+				n--
+			}
+		}
+		pos += end + 1
+	}
+	return -1
+}
+
+func (d *assetDocument) problem(path []string, format string, args ...interface{}) Problem {
+	line, column := d.locate(path)
+	return Problem{d.File, line, column, fmt.Sprintf(format, args...)}
+}
+
+// parseAsset checks that content is well-formed UTF-8 encoded JSON, YAML
+// or RAML according to its content type, and decodes it.
+func parseAsset(content []byte, file string) (*assetDocument, *Problem) {
+	doc := &assetDocument{File: file, ContentType: assetContentType(file), Content: content}
+	fail := func(line, column int, format string, args ...interface{}) (*assetDocument, *Problem) {
+		return nil, &Problem{file, line, column, fmt.Sprintf(format, args...)}
+	}
+
+	if len(content) == 0 {
+		return fail(0, 0, "input must not be empty")
 	}
 
 	//UTF-16 or UTF-32?
-	utfEndiannessSet := bytes.HasPrefix(*a, []byte{0xff, 0xfe}) || //UTF-16LE
-		bytes.HasPrefix(*a, []byte{0xfe, 0xff}) || //UTF-16BE
-		bytes.HasPrefix(*a, []byte{0x00, 0x00, 0xfe, 0xff}) || //UTF-32LE
-		bytes.HasPrefix(*a, []byte{0x00, 0x00, 0xff, 0xfe}) //UTF-32BE
+	utfEndiannessSet := bytes.HasPrefix(content, []byte{0xff, 0xfe}) || //UTF-16LE
+		bytes.HasPrefix(content, []byte{0xfe, 0xff}) || //UTF-16BE
+		bytes.HasPrefix(content, []byte{0x00, 0x00, 0xfe, 0xff}) || //UTF-32LE
+		bytes.HasPrefix(content, []byte{0x00, 0x00, 0xff, 0xfe}) //UTF-32BE
 
 	//for UTF-16 or UTF-32, let JSON/YAML parsers test validity
 	//otherwise ensure UTF-8 validity
-	if utfEndiannessSet == false && utf8.Valid(*a) == false {
-		return "", errors.New("invalid UTF-8")
+	if utfEndiannessSet == false && utf8.Valid(content) == false {
+		return fail(0, 0, "invalid UTF-8")
 	}
 
-	//if extension indicates YAML, attempt conversion
-	//(otherwise assume JSON)
-	reYaml := regexp.MustCompile("(?i)\\.ya?ml$") //'a' is optional
-	isYaml := reYaml.FindStringIndex(file) != nil
-	reRaml := regexp.MustCompile("(?i)\\.raml$") //'a' is obligatory
-	isRaml := reRaml.FindStringIndex(file) != nil
+	if doc.ContentType == contentTypeRAML && bytes.HasPrefix(content, []byte("#%RAML")) == false {
+		return fail(1, 1, "invalid RAML: expected RAML comment line")
+	}
 
-	if isRaml {
-		if bytes.HasPrefix(*a, []byte("#%RAML")) == false {
-			return "", errors.New(fmt.Sprint("invalid RAML: expected RAML comment line"))
+	//YAML (and RAML) is converted to JSON first
+	jsonbytes := content
+	if doc.ContentType != contentTypeJSON {
+		var err error
+		jsonbytes, err = yaml.YAMLToJSON(content)
+		if err != nil {
+			return fail(yamlErrorLine(err), 0, "invalid YAML: %v", err)
 		}
 	}
 
-	if isYaml || isRaml {
-		jsonbytes, err := yaml.YAMLToJSON(*a)
-		if err != nil {
-			return "", errors.New(fmt.Sprintf("invalid YAML: %v", err))
+	if err := json.Unmarshal(jsonbytes, &doc.Value); err != nil {
+		if doc.ContentType != contentTypeJSON {
+			return fail(0, 0, "invalid YAML(2): %v", err)
 		}
-		var anyjson interface{}
-		err = json.Unmarshal(jsonbytes, &anyjson)
-		if err != nil {
-			return "", errors.New(fmt.Sprintf("invalid YAML(2): %v", err))
+		line, column := 0, 0
+		switch e := err.(type) {
+		case *json.SyntaxError:
+			line, column = doc.position(int(e.Offset))
+		case *json.UnmarshalTypeError:
+			line, column = doc.position(int(e.Offset))
 		}
-		return "application/x-yaml", nil
+		return fail(line, column, "invalid JSON: %v", err)
+	}
+	return doc, nil
+}
+
+var reYamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+func yamlErrorLine(err error) int {
+	m := reYamlErrorLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
 	}
+	line, _ := strconv.Atoi(m[1])
+	return line
+}
 
-	//now parse the JSON
-	var any interface{}
-	err := json.Unmarshal(*a, &any)
-	if err != nil {
-		return "", errors.New(fmt.Sprintf("invalid JSON: %v", err))
+// checkAsset returns all problems of an asset: whether it is well-formed
+// and, if so, what the validators for its kind of document report.
+func checkAsset(content []byte, file string) []Problem {
+	doc, problem := parseAsset(content, file)
+	if problem != nil {
+		return []Problem{*problem}
+	}
+	return validateAsset(doc)
+}
+
+// validateAsset returns what the validators for the kind of doc report.
+func validateAsset(doc *assetDocument) []Problem {
+	file := doc.File
+	problems := make([]Problem, 0)
+	for _, v := range validators[doc.ContentType] {
+		if v.Accepts(doc) {
+			Log.Debugf("Checking %s with %s validator", file, v.Name())
+			problems = append(problems, v.Validate(doc)...)
+		}
 	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+	return problems
+}
 
-	return "application/json", nil
+// preflightAsset checks an asset before it is uploaded as data URI. It
+// returns the mime type to upload it with, or the first problem found.
+func preflightAsset(a *[]byte, file string) (string, error) {
+	if len(*a) > maxAssetLen {
+		return "", newError(exitValidation, "input length must not exceed %d", maxAssetLen)
+	}
+	return preflightContent(*a, file)
+}
+
+// preflightContent is preflightAsset regardless of the size. Only assets
+// that can't be parsed are refused; what the validators find is printed
+// as warning, as they only know part of each specification.
+func preflightContent(content []byte, file string) (string, error) {
+	doc, p := parseAsset(content, file)
+	if p != nil {
+		if p.Line > 0 {
+			return "", newError(exitValidation, "line %d: %s", p.Line, p.Message)
+		}
+		return "", newError(exitValidation, "%s", p.Message)
+	}
+	for _, p := range validateAsset(doc) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", p)
+	}
+
+	if assetContentType(file) == contentTypeJSON {
+		return contentTypeJSON, nil
+	}
+	return contentTypeYAML, nil
+}
+
+// checker collects the problems found by a validator.
+type checker struct {
+	doc      *assetDocument
+	problems []Problem
+}
+
+func (c *checker) report(path []string, format string, args ...interface{}) {
+	c.problems = append(c.problems, c.doc.problem(path, format, args...))
+}
+
+// at returns path extended by keys, leaving path untouched.
+func at(path []string, keys ...string) []string {
+	return append(append(make([]string, 0, len(path)+len(keys)), path...), keys...)
+}
+
+func index(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+func isIndex(key string) bool {
+	return strings.HasPrefix(key, "[") && strings.HasSuffix(key, "]")
+}
+
+// pathString formats a path for messages, e.g. paths./pets.get.parameters[0].
+func pathString(path []string) string {
+	var s strings.Builder
+	for i, key := range path {
+		if i > 0 && !isIndex(key) {
+			s.WriteString(".")
+		}
+		s.WriteString(key)
+	}
+	return s.String()
+}
+
+// object returns m[key] if it is an object. Missing required keys and
+// values of other types are reported.
+func (c *checker) object(m map[string]interface{}, path []string, key string, required bool) (map[string]interface{}, bool) {
+	v, ok := m[key]
+	if !ok {
+		if required {
+			c.report(path, "%s is required", pathString(at(path, key)))
+		}
+		return nil, false
+	}
+	o, ok := v.(map[string]interface{})
+	if !ok {
+		c.report(at(path, key), "%s must be an object", pathString(at(path, key)))
+	}
+	return o, ok
+}
+
+// array is like object for arrays.
+func (c *checker) array(m map[string]interface{}, path []string, key string, required bool) ([]interface{}, bool) {
+	v, ok := m[key]
+	if !ok {
+		if required {
+			c.report(path, "%s is required", pathString(at(path, key)))
+		}
+		return nil, false
+	}
+	a, ok := v.([]interface{})
+	if !ok {
+		c.report(at(path, key), "%s must be an array", pathString(at(path, key)))
+	}
+	return a, ok
+}
+
+// str is like object for strings, which must not be empty.
+func (c *checker) str(m map[string]interface{}, path []string, key string, required bool) (string, bool) {
+	v, ok := m[key]
+	if !ok {
+		if required {
+			c.report(path, "%s is required", pathString(at(path, key)))
+		}
+		return "", false
+	}
+	s, ok := v.(string)
+	if !ok || s == "" {
+		c.report(at(path, key), "%s must be a non-empty string", pathString(at(path, key)))
+	}
+	return s, ok
+}
+
+// checkLocalRefs reports $refs within the document ("#/...") that do not
+// resolve.
+func (c *checker) checkLocalRefs(v interface{}, path []string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedMapKeys(v) {
+			if ref, ok := v[key].(string); ok && key == "$ref" && strings.HasPrefix(ref, "#") {
+				if _, ok := resolvePointer(c.doc.Value, strings.TrimPrefix(ref, "#")); !ok {
+					c.report(at(path, key), "%s does not resolve", ref)
+				}
+				continue
+			}
+			c.checkLocalRefs(v[key], at(path, key))
+		}
+	case []interface{}:
+		for i, item := range v {
+			c.checkLocalRefs(item, at(path, index(i)))
+		}
+	}
+}
+
+// resolvePointer resolves a JSON pointer (RFC 6901) within v.
+func resolvePointer(v interface{}, pointer string) (interface{}, bool) {
+	if pointer == "" {
+		return v, true
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, false
+	}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = node[token]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
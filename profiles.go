@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+)
+
+const defaultProfileName = "default"
+
+var fProfile *string
+
+type SlyftProfile struct {
+	Backend string `json:"backend,omitempty"`
+	// Auth is only kept here with the file credential store
+	Auth *SlyftAuth `json:"auth,omitempty"`
+}
+
+// currentProfileName returns the profile chosen by --profile/SLYFT_PROFILE,
+// falling back to the one selected by `slyft user profiles use`.
+func currentProfileName(sr *SlyftRC) string {
+	if fProfile != nil && strings.TrimSpace(*fProfile) != "" {
+		return strings.TrimSpace(*fProfile)
+	}
+	if sr != nil && sr.Current != "" {
+		return sr.Current
+	}
+	return defaultProfileName
+}
+
+// profile returns the named profile, creating it if necessary. Configs
+// written before profiles existed are migrated into the default profile.
+func (sr *SlyftRC) profile(name string) *SlyftProfile {
+	if sr.Profiles == nil {
+		sr.Profiles = make(map[string]*SlyftProfile)
+	}
+	if sr.Auth != nil {
+		if _, ok := sr.Profiles[defaultProfileName]; !ok {
+			sr.Profiles[defaultProfileName] = &SlyftProfile{Auth: sr.Auth}
+		}
+		sr.Auth = nil
+	}
+	p, ok := sr.Profiles[name]
+	if !ok {
+		p = &SlyftProfile{}
+		sr.Profiles[name] = p
+	}
+	return p
+}
+
+// selectProfileBackend makes the backend of the current profile the
+// target of all requests, unless SLYFTBACKEND overrides it. It reports
+// whether a backend was chosen by either.
+func selectProfileBackend() bool {
+	if backend := os.Getenv("SLYFTBACKEND"); backend != "" {
+		BackendBaseUrl = backend
+		return true
+	}
+	sr, err := readConfig()
+	if err != nil {
+		return false
+	}
+	if p := sr.profile(currentProfileName(sr)); p.Backend != "" {
+		BackendBaseUrl = p.Backend
+		Log.Debugf("Using backend %s", BackendBaseUrl)
+		return true
+	}
+	return false
+}
+
+func listProfiles(cmd *cli.Cmd) {
+	cmd.Action = action(func() error {
+		sr, _ := readConfig()
+		current := currentProfileName(sr)
+		sr.profile(current)
+
+		names := make([]string, 0, len(sr.Profiles))
+		for name := range sr.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		store, err := credentialStore(sr)
+		if err != nil {
+			return ReportError("Listing profiles", err)
+		}
+		auths := make(map[string]*SlyftAuth)
+		for _, name := range names {
+			auth, err := store.Load(name)
+			if err != nil {
+				Log.Debugf("Loading credentials of %s: %v", name, err)
+				auth = &SlyftAuth{}
+			}
+			auths[name] = auth
+		}
+
+		if structuredOutput() {
+			type profileInfo struct {
+				Name     string `json:"name"`
+				Backend  string `json:"backend"`
+				User     string `json:"user"`
+				Current  bool   `json:"current"`
+				LoggedIn bool   `json:"logged_in"`
+			}
+			infos := make([]profileInfo, 0, len(names))
+			for _, name := range names {
+				auth := auths[name]
+				infos = append(infos, profileInfo{name, sr.Profiles[name].Backend, auth.Uid, name == current, auth.GoodForLogin()})
+			}
+			displayStructured(infos)
+			return nil
+		}
+
+		data := [][]string{{"Current", "Name", "Backend", "User"}}
+		for _, name := range names {
+			marker := ""
+			if name == current {
+				marker = "*"
+			}
+			user := auths[name].Uid
+			if !auths[name].GoodForLogin() {
+				user = "(not logged in)"
+			}
+			data = append(data, []string{marker, name, sr.Profiles[name].Backend, user})
+		}
+		fmt.Fprint(os.Stdout, markdownTable(&data))
+		return nil
+	})
+}
+
+func useProfile(cmd *cli.Cmd) {
+	cmd.Spec = "NAME [--backend]"
+	name := cmd.StringArg("NAME", "", "Name of the profile")
+	backend := cmd.StringOpt("backend b", "", "Backend URL of the profile (default: production backend)")
+
+	cmd.Action = action(func() error {
+		*name = strings.TrimSpace(*name)
+		if *name == "" {
+			statusf("NAME must not be empty.\n")
+			return newError(exitUsage, "NAME must not be empty.")
+		}
+
+		err := updateConfig(func(sr *SlyftRC) error {
+			p := sr.profile(*name)
+			if *backend != "" {
+				p.Backend = strings.TrimSpace(*backend)
+			}
+			sr.Current = *name
+			return nil
+		})
+		if err != nil {
+			return ReportError("Switching profile", err)
+		}
+		statusf("Now using profile %s\n", *name)
+		if auth, err := readAuthFromConfig(); err != nil || !auth.GoodForLogin() {
+			statusf("You are not logged in with this profile yet. Please do a `slyft user login`\n")
+		}
+		return nil
+	})
+}
+
+func removeProfile(cmd *cli.Cmd) {
+	cmd.Spec = "NAME"
+	name := cmd.StringArg("NAME", "", "Name of the profile")
+
+	cmd.Action = action(func() error {
+		err := updateConfig(func(sr *SlyftRC) error {
+			sr.profile(defaultProfileName)
+			if _, ok := sr.Profiles[*name]; !ok {
+				return newError(exitNotFound, "No profile named %s", *name)
+			}
+			store, err := credentialStore(sr)
+			if err != nil {
+				return err
+			}
+			if err := store.Delete(*name); err != nil {
+				return err
+			}
+			delete(sr.Profiles, *name)
+			if sr.Current == *name {
+				sr.Current = ""
+			}
+			return nil
+		})
+		if err != nil {
+			return ReportError("Removing profile", err)
+		}
+		statusf("Removed profile %s\n", *name)
+		return nil
+	})
+}
+
+func RegisterProfileRoutes(profiles *cli.Cmd) {
+	profiles.Command("list ls", "List your profiles", listProfiles)
+	profiles.Command("use", "Switch to (or create) a profile", useProfile)
+	profiles.Command("remove rm", "Remove a profile including its credentials", removeProfile)
+}
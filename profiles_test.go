@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestProfileMigration(t *testing.T) {
+	legacy := []byte(`{"Auth": {"access_token": "token", "client": "client", "uid": "foo@bar.boo"}}`)
+
+	var sr SlyftRC
+	if err := json.Unmarshal(legacy, &sr); err != nil {
+		t.Fatalf("Must parse legacy config: %v", err)
+	}
+
+	p := sr.profile(currentProfileName(&sr))
+	if p.Auth.Uid != "foo@bar.boo" || !p.Auth.GoodForLogin() {
+		t.Errorf("Legacy auth must end up in the default profile, got %#v", p)
+	}
+	if sr.Auth != nil {
+		t.Error("Legacy auth must be removed after migration")
+	}
+}
+
+func TestCurrentProfileName(t *testing.T) {
+	sr := &SlyftRC{Current: "staging"}
+	if name := currentProfileName(sr); name != "staging" {
+		t.Errorf("Expected current profile staging, got %s", name)
+	}
+
+	flag := "production"
+	fProfile = &flag
+	defer func() { fProfile = nil }()
+	if name := currentProfileName(sr); name != "production" {
+		t.Errorf("--profile must take precedence, got %s", name)
+	}
+
+	if name := currentProfileName(&SlyftRC{}); name != "production" {
+		t.Errorf("Expected production, got %s", name)
+	}
+	fProfile = nil
+	if name := currentProfileName(&SlyftRC{}); name != defaultProfileName {
+		t.Errorf("Expected default profile, got %s", name)
+	}
+}
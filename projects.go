@@ -2,49 +2,34 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"net/http"
 	"os"
 	"strconv"
-	"time"
 
 	"strings"
 
 	"github.com/jawher/mow.cli"
+	"github.com/thingforward/slyft-cli/client"
 )
 
-type Project struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Details   string    `json:"details"`
-	Settings  string    `json:"settings"`
-	UserID    int       `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+// Project is a client.Project as shown and chosen by the commands.
+type Project client.Project
+
+// toProjects converts the projects returned by the client.
+func toProjects(projects []client.Project) []Project {
+	converted := make([]Project, len(projects))
+	for i := range projects {
+		converted[i] = Project(projects[i])
+	}
+	return converted
 }
 
 func (p *Project) getName() string {
 	return p.Name
 }
 
-type ProjectParam struct {
-	Project Project `json:"project"`
-}
-
-type SearchString struct {
-	SearchString string `json:"search_string"`
-}
-
-func createProjectParam(name, details, settings string) *ProjectParam {
-	return &ProjectParam{
-		Project{
-			Name:     name,
-			Details:  details,
-			Settings: settings,
-		},
-	}
+func (p *Project) delete(c *client.Client) error {
+	return c.DeleteProject(p.ID)
 }
 
 func ReadUserIntInput(prompt string) (int, error) {
@@ -57,6 +42,9 @@ func ReadUserIntInput(prompt string) (int, error) {
 }
 
 func ReadUserInput(prompt string) string {
+	if !interactive() {
+		return ""
+	}
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Print(prompt)
 	resp, err := reader.ReadString('\n')
@@ -72,6 +60,11 @@ func (p *Project) Display() { // String?
 		return
 	}
 
+	if structuredOutput() {
+		displayStructured(p)
+		return
+	}
+
 	data := [][]string{
 		[]string{"Key", "Value"},
 		[]string{"ID", strconv.Itoa(p.ID)},
@@ -88,6 +81,11 @@ func (p *Project) Display() { // String?
 }
 
 func DisplayProjects(projects []Project) {
+	if structuredOutput() {
+		displayStructured(projects)
+		return
+	}
+
 	if len(projects) == 0 {
 		fmt.Println("No projects found")
 		return
@@ -108,97 +106,80 @@ func DisplayProjects(projects []Project) {
 	fmt.Fprint(os.Stdout, markdownTable(&data))
 }
 
-func extractProjectsFromBody(body []byte) ([]Project, error) {
-	//Log.Debugf("body=%v", string(body))
-	projects := make([]Project, 0)
-	if err := json.Unmarshal(body, &projects); err != nil {
-		return nil, err
-	}
-	return projects, nil
-}
-
-func extractProjectFromBody(body []byte) ([]Project, error) {
-	//Log.Debugf("body=%v", string(body))
-	p := &Project{}
-
-	if err := json.Unmarshal(body, &p); err != nil {
-		return nil, err
-	}
-	return []Project{*p}, nil
-}
-
-func displayProjectsFromResponse(resp *http.Response, expectedCode int, listExpected bool) error {
-	projects, err := extractProjectFromResponse(resp, expectedCode, listExpected)
+// showProjectById displays the current state of the project with id.
+func showProjectById(id int) error {
+	c, err := authClient()
 	if err != nil {
-		Log.Fatalf("Unable to list projects: %s", err)
 		return err
 	}
-	Log.Debugf("projects=%+v", projects)
-
-	if listExpected {
-		DisplayProjects(projects)
-	} else {
-		if len(projects) == 1 {
-			projects[0].Display()
-		}
+	p, err := c.GetProject(id)
+	if err != nil {
+		return err
 	}
-
+	(*Project)(p).Display()
 	return nil
 }
 
 func createProject(cmd *cli.Cmd) {
-	cmd.Spec = "[--name] [--remember]"
+	cmd.Spec = "[--name] [--details] [--remember]"
 	name := cmd.StringOpt("name n", "", "Name for the project")
+	details := cmd.StringOpt("details", "", "Details to the project (optional)")
 	remember := cmd.BoolOpt("remember r", false, "Remember project name in the current directory")
 
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
 		*name = strings.TrimSpace(*name)
 		if *name == "" {
 			temp := ReadUserInput("Please provide project name: ")
 			name = &temp
 			if strings.TrimSpace(*name) == "" {
-				fmt.Println("The project name cannot be empty")
-				cli.Exit(1)
+				statusf("The project name cannot be empty\n")
+				return newError(exitUsage, "The project name cannot be empty")
 			}
 		} else {
-			fmt.Printf("Project Name: %s\n", *name)
+			statusf("Project Name: %s\n", *name)
 		}
 
-		projectDetails := ReadUserInput("Details to the project (optional): ")
-		resp, err := Do("/v1/projects", "POST", createProjectParam(*name, projectDetails, ""))
+		projectDetails := strings.TrimSpace(*details)
+		if projectDetails == "" {
+			projectDetails = ReadUserInput("Details to the project (optional): ")
+		}
+		c, err := authClient()
 		if err != nil {
-			ReportError("Contacting the server", err)
-			return
+			return ReportError("Contacting the server", err)
 		}
-		defer resp.Body.Close()
-		displayProjectsFromResponse(resp, http.StatusCreated, false)
+		created, err := c.CreateProject(*name, projectDetails)
+		if err != nil {
+			return ReportError("Creating the project", err)
+		}
+		(*Project)(created).Display()
 
 		if remember != nil && *remember {
 			_, err := os.Open(".slyftproject")
 			if err == nil {
-				fmt.Println("--remember was chosen, but there is already a .slyftproject file. Leaving as-is")
+				statusf("--remember was chosen, but there is already a .slyftproject file. Leaving as-is\n")
 			} else {
-				fmt.Println("Remembering this project in file .slyftproject")
+				statusf("Remembering this project in file .slyftproject\n")
 
 				slyftProjectFile, err := os.Create(".slyftproject")
 				defer slyftProjectFile.Close()
 				if err != nil {
-					fmt.Println("--remember was chosen, but was unable to create a .slyftproject here.")
-					fmt.Println("Please create manually or use --project/--name parameters")
+					statusf("--remember was chosen, but was unable to create a .slyftproject here.\n")
+					statusf("Please create manually or use --project/--name parameters\n")
 					Log.Debug(err)
 				} else {
 					w := bufio.NewWriter(slyftProjectFile)
 					_, err := w.WriteString(*name)
 					if err != nil {
-						fmt.Println("--remember was chosen, but was unable to write to .slyftproject here.")
-						fmt.Println("Please check manually or use --project/--name parameters")
+						statusf("--remember was chosen, but was unable to write to .slyftproject here.\n")
+						statusf("Please check manually or use --project/--name parameters\n")
 						Log.Debug(err)
 					}
 					w.Flush()
 				}
 			}
 		}
-	}
+		return nil
+	})
 }
 
 func settingsProject(cmd *cli.Cmd) {
@@ -206,10 +187,10 @@ func settingsProject(cmd *cli.Cmd) {
 	name := cmd.StringOpt("name", "", "Name for the project")
 	key := cmd.StringArg("KEY", "", "Name of the setting")
 	value := cmd.StringArg("VALUE", "", "Value of the setting")
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
 		if *key == "" {
-			fmt.Println("KEY must not be empty.")
-			return
+			statusf("KEY must not be empty.\n")
+			return newError(exitUsage, "KEY must not be empty.")
 		}
 
 		if *name == "" {
@@ -217,97 +198,99 @@ func settingsProject(cmd *cli.Cmd) {
 		}
 		p, err := chooseProject(*name, "Which project needs to be updated: ")
 		if err == nil {
-			resp, err := Do(p.EndPoint(), "PUT", createProjectParam("", "", fmt.Sprintf(`{"%s": "%s"}`, *key, *value)))
-			defer resp.Body.Close()
-			if err != nil || resp.StatusCode != http.StatusNoContent {
-				fmt.Printf("Something went wrong: %s\n", err)
-			} else {
-				fmt.Println("Successfully updated")
-				resp, err := Do(p.EndPoint(), "GET", nil)
-				defer resp.Body.Close()
-				if err == nil {
-					displayProjectsFromResponse(resp, http.StatusOK, false)
-					return
-				}
+			c, err := authClient()
+			if err == nil {
+				err = c.UpdateProjectSettings(p.ID, fmt.Sprintf(`{"%s": "%s"}`, *key, *value))
+			}
+			if err != nil {
+				statusf("Something went wrong: %s\n", err)
+				return err
 			}
-			return
+			statusf("Successfully updated\n")
+			if err := showProjectById(p.ID); err != nil {
+				return ReportError("Showing the project", err)
+			}
+			return nil
 		}
-		ReportError("Choosing a project", err)
-	}
+		return ReportError("Choosing a project", err)
+	})
 }
 
 func FindProjectById(id int) (*Project, error) {
 	//TODO: ensure only one match possible provided IDs are unique
-	resp, err := Do("/v1/projects", "GET", nil)
+	c, err := authClient()
 	if err != nil {
 		return nil, err
 	}
-
-	defer resp.Body.Close()
-	projects, err := extractProjectFromResponse(resp, http.StatusOK, true)
+	projects, err := c.ListProjects()
 	if err != nil {
 		return nil, err
 	}
 
-	notFoundError := errors.New(fmt.Sprintf("No project with ID %d", id))
-
-	if len(projects) == 0 {
-		return nil, notFoundError
-	}
-
 	for _, project := range projects {
 		if project.ID == id {
-			return &project, nil
+			return (*Project)(&project), nil
 		}
 	}
-	return nil, notFoundError
+	return nil, newError(exitNotFound, "No project with ID %d", id)
 }
 
-func FindProjects(portion string) (*http.Response, error) {
-	if strings.TrimSpace(portion) == "" {
-		return Do("/v1/projects", "GET", nil)
+// FindProjects returns the projects whose name contains portion, or all
+// projects if portion is empty.
+func FindProjects(portion string) ([]Project, error) {
+	c, err := authClient()
+	if err != nil {
+		return nil, err
+	}
+	projects, err := c.SearchProjects(portion)
+	if err != nil {
+		return nil, err
 	}
-	return Do("/v1/projects/search", "GET", &SearchString{portion})
+	return toProjects(projects), nil
 }
 
 func listProjects(cmd *cli.Cmd) {
 	cmd.Spec = "[--name]"
 	name := cmd.StringOpt("name", "", "Name for the project")
 
-	cmd.Action = func() {
-		resp, err := FindProjects(*name)
-		Log.Debugf("err=%#v", err)
-		Log.Debugf("resp=%#v", resp)
+	cmd.Action = action(func() error {
+		projects, err := FindProjects(*name)
 		if err != nil {
-			ReportError("Listing the projects", err)
-			return
+			return ReportError("Listing the projects", err)
 		}
-		defer resp.Body.Close()
-		displayProjectsFromResponse(resp, http.StatusOK, true)
-	}
+		Log.Debugf("projects=%+v", projects)
+		DisplayProjects(projects)
+		return nil
+	})
 }
 
 func chooseProject(portion, message string) (*Project, error) {
-	resp, err := FindProjects(portion)
-	if err != nil {
-		Log.Debugf("chooseProject: err=%s", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-	projects, err := extractProjectFromResponse(resp, http.StatusOK, true)
+	projects, err := FindProjects(portion)
 	if err != nil {
 		Log.Debugf("chooseProject: err=%s", err)
 		return nil, err
 	}
 
 	if len(projects) == 0 {
-		return nil, errors.New("No such project. Sorry")
+		return nil, newError(exitNotFound, "No such project. Sorry")
 	}
 
 	if len(projects) == 1 {
 		return &projects[0], nil
 	}
 
+	if !interactive() {
+		// an exact name match is not ambiguous
+		candidates := make([]string, 0, len(projects))
+		for i := range projects {
+			if projects[i].Name == portion {
+				return &projects[i], nil
+			}
+			candidates = append(candidates, projects[i].Name)
+		}
+		return nil, ambiguousChoice("project", candidates)
+	}
+
 	DisplayProjects(projects)
 
 	choice, err := ReadUserIntInput(message)
@@ -316,69 +299,45 @@ func chooseProject(portion, message string) (*Project, error) {
 	}
 
 	if choice > len(projects) {
-		return nil, errors.New("Please choose a number from the first column")
+		return nil, newError(exitUsage, "Please choose a number from the first column")
 	}
 
 	return &projects[choice-1], nil
 }
 
-func (p *Project) EndPoint() string {
-	return fmt.Sprintf("/v1/projects/%d", p.ID)
-}
-
-func (p *Project) AssetUrl(id int) string {
-	return fmt.Sprintf("%s/%d", p.AssetsUrl(), id)
-}
-
-func (p *Project) AssetsUrl() string {
-	return p.EndPoint() + "/assets"
-}
-
-func (p *Project) AssetstoreUrl() string {
-	return p.EndPoint() + "/assetstore"
-}
-
-func (p *Project) JobsUrl() string {
-	return p.EndPoint() + "/jobs"
-}
-
 func showProject(cmd *cli.Cmd) {
 	cmd.Spec = "[--name]"
 	name := cmd.StringOpt("name", "", "Name of the project")
 
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
 		if *name == "" {
 			*name, _ = ReadProjectLock()
 		}
 		p, err := chooseProject(*name, "Which project needs to be displayed in detail: ")
 		if err == nil {
-			resp, err := Do(p.EndPoint(), "GET", nil)
-			defer resp.Body.Close()
-			if err == nil {
-				displayProjectsFromResponse(resp, http.StatusOK, false)
-				return
-			}
+			err = showProjectById(p.ID)
 		}
-		ReportError("Showing project", err)
-		return
-	}
+		if err != nil {
+			return ReportError("Showing project", err)
+		}
+		return nil
+	})
 }
 
 func deleteProject(cmd *cli.Cmd) {
 	cmd.Spec = "[--name]"
 	name := cmd.StringOpt("name", "", "Name (or part of it) of the project")
 
-	cmd.Action = func() {
+	cmd.Action = action(func() error {
 		if *name == "" {
 			*name, _ = ReadProjectLock()
 		}
 		p, err := chooseProject(*name, "Please choose the project to be deleted: ")
 		if err != nil {
-			ReportError("Deleting project", err)
-			return
+			return ReportError("Deleting project", err)
 		}
-		DeleteApiModel(p)
-	}
+		return DeleteApiModel(p)
+	})
 }
 
 func RegisterProjectRoutes(proj *cli.Cmd) {
@@ -393,4 +352,6 @@ func RegisterProjectRoutes(proj *cli.Cmd) {
 	proj.Command("build b", "Build a project", buildProject)
 	proj.Command("validate v", "Validate a project", validateProject)
 	proj.Command("status st", "Show job status of a projct", jobStatusProject)
+	proj.Command("artifacts art", "Download the results of a build", projectArtifacts)
+	proj.Command("watch", "Upload changed assets and validate them, until stopped", watchProject)
 }
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reportFormats are the formats of `--report`, mapped to their writers.
+var reportFormats = map[string]func(job *Job, diagnostics []Diagnostic) ([]byte, error){
+	"sarif":       sarifReport,
+	"junit":       junitReport,
+	"codeclimate": codeClimateReport,
+}
+
+func checkReportFormat(format string) error {
+	if _, ok := reportFormats[strings.ToLower(format)]; !ok {
+		return newError(exitUsage, "Unknown report format %s, choose one of %s", format, strings.Join(sortedReportFormats(), ", "))
+	}
+	return nil
+}
+
+func sortedReportFormats() []string {
+	formats := make([]string, 0, len(reportFormats))
+	for format := range reportFormats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// writeJobReport writes the diagnostics of job to file in the given
+// format.
+func writeJobReport(job *Job, format, file string) error {
+	if err := checkReportFormat(format); err != nil {
+		return err
+	}
+	content, err := reportFormats[strings.ToLower(format)](job, job.Diagnostics())
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(file, content, 0644); err != nil {
+		return err
+	}
+	if !structuredOutput() {
+		fmt.Printf("Wrote %s report to %s\n", strings.ToLower(format), file)
+	}
+	return nil
+}
+
+// SARIF 2.1.0, as read by code scanning tools.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func sarifReport(job *Job, diagnostics []Diagnostic) ([]byte, error) {
+	run := sarifRun{
+		Tool:    sarifTool{sarifDriver{Name: "slyft", Version: VERSION, InformationURI: "https://slyft.io"}},
+		Results: make([]sarifResult, 0, len(diagnostics)),
+	}
+	rules := make(map[string]bool)
+	for _, d := range diagnostics {
+		r := sarifResult{RuleID: d.Rule, Level: sarifLevel(d.Severity), Message: sarifMessage{d.Message}}
+		if d.Asset != "" {
+			loc := sarifLocation{sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{d.Asset}}}
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{d.Line, d.Column}
+			}
+			r.Locations = []sarifLocation{loc}
+		}
+		run.Results = append(run.Results, r)
+		if d.Rule != "" && !rules[d.Rule] {
+			rules[d.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{d.Rule})
+		}
+	}
+	return json.MarshalIndent(&sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case severityError:
+		return "error"
+	case severityWarning:
+		return "warning"
+	}
+	return "note"
+}
+
+// JUnit XML, as read by test dashboards. Each asset is a test case,
+// failing if it has errors.
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitReport(job *Job, diagnostics []Diagnostic) ([]byte, error) {
+	suite := junitTestSuite{Name: fmt.Sprintf("slyft %s %s", job.Kind, job.ProjectName)}
+	byAsset := make(map[string][]Diagnostic)
+	for _, d := range diagnostics {
+		byAsset[d.Asset] = append(byAsset[d.Asset], d)
+	}
+	if len(byAsset) == 0 {
+		// without diagnostics, the job itself is the test case
+		byAsset[""] = nil
+	}
+
+	assets := make([]string, 0, len(byAsset))
+	for asset := range byAsset {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+	for _, asset := range assets {
+		tc := junitTestCase{Name: asset, ClassName: suite.Name}
+		if asset == "" {
+			tc.Name = fmt.Sprintf("job %d", job.ID)
+		}
+		var errs, others []string
+		for _, d := range byAsset[asset] {
+			if d.Severity == severityError {
+				errs = append(errs, d.String())
+			} else {
+				others = append(others, d.String())
+			}
+		}
+		if asset == "" && len(errs) == 0 && job.failed() {
+			errs = append(errs, job.Results.ResultMessage)
+		}
+		if len(errs) > 0 {
+			tc.Failure = &junitFailure{Message: fmt.Sprintf("%d error(s)", len(errs)), Type: severityError, Text: strings.Join(errs, "\n")}
+			suite.Failures++
+		}
+		tc.SystemOut = strings.Join(others, "\n")
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+
+	content, err := xml.MarshalIndent(&junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+// Code Climate issues, as read by GitLab code quality reports.
+
+type codeClimateIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeClimateLocation `json:"location"`
+}
+
+type codeClimateLocation struct {
+	Path  string           `json:"path"`
+	Lines codeClimateLines `json:"lines"`
+}
+
+type codeClimateLines struct {
+	Begin int `json:"begin"`
+}
+
+func codeClimateReport(job *Job, diagnostics []Diagnostic) ([]byte, error) {
+	issues := make([]codeClimateIssue, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		check := d.Rule
+		if check == "" {
+			check = job.Kind
+		}
+		line := d.Line
+		if line == 0 {
+			line = 1
+		}
+		// the path is required, findings of the whole project have none
+		path := d.Asset
+		if path == "" {
+			path = codeClimateProjectPath(job)
+		}
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%s\x00%s", d.Asset, d.Line, d.Column, d.Rule, d.Message)))
+		issues = append(issues, codeClimateIssue{
+			Description: d.Message,
+			CheckName:   check,
+			Fingerprint: hex.EncodeToString(sum[:16]),
+			Severity:    codeClimateSeverity(d.Severity),
+			Location:    codeClimateLocation{path, codeClimateLines{line}},
+		})
+	}
+	return json.MarshalIndent(issues, "", "  ")
+}
+
+func codeClimateProjectPath(job *Job) string {
+	if job.ProjectName != "" {
+		return job.ProjectName
+	}
+	return fmt.Sprintf("job %d", job.ID)
+}
+
+func codeClimateSeverity(severity string) string {
+	switch severity {
+	case severityError:
+		return "major"
+	case severityWarning:
+		return "minor"
+	}
+	return "info"
+}
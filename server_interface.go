@@ -1,77 +1,64 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"sync"
+
+	"github.com/thingforward/slyft-cli/client"
 )
 
-func Do(resource, method string, params interface{}) (*http.Response, error) {
+// authClient returns an API client with the stored credentials.
+func authClient() (*client.Client, error) {
 	auth, err := readAuthFromConfig()
 	if err != nil {
-		fmt.Println("You do not seem to be logged in. Please do a `slyft user login`")
-		return nil, err
+		fmt.Fprintln(os.Stderr, "You do not seem to be logged in. Please do a `slyft user login`")
+		return nil, &Error{exitAuth, err}
 	}
-	return DoAuth(resource, method, params, auth)
-}
-
-func DoAuth(resource, method string, params interface{}, auth *SlyftAuth) (*http.Response, error) {
-	if auth == nil || !auth.GoodForLogin() {
-		fmt.Println("You do not seem to be logged in. Please do a `slyft user login`")
-		return nil, errors.New("Not logged in.")
+	if !auth.GoodForLogin() {
+		fmt.Fprintln(os.Stderr, "You do not seem to be logged in. Please do a `slyft user login`")
+		return nil, newError(exitAuth, "Not logged in.")
 	}
-
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(params)
-	req, err := http.NewRequest(method, ServerURL(resource), b)
-
-	if err != nil {
-		Log.Critical("Failed to create a request: " + err.Error())
-		return nil, err
-	}
-
-	addAuthToHeader(&req.Header, auth)
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	client := &http.Client{}
-
-	//Log.Debugf("auth=%#v", auth)
-	Log.Debugf("req=%#v", req)
-
-	resp, err := client.Do(req)
-	Log.Debugf("resp=%#v", resp)
-
-	if err != nil {
-		Log.Debugf("err=%#v", err)
-	}
-
-	return resp, err
+	return newClient(auth), nil
 }
 
-func DoNoAuth(resource, method string, params interface{}) (*http.Response, error) {
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(params)
-	req, err := http.NewRequest(method, ServerURL(resource), b)
-
-	if err != nil {
-		Log.Critical("Failed to create a request: " + err.Error())
-		return nil, err
-	}
+var fRetries *int
 
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	Log.Debugf("req=%#v", req)
-	Log.Debugf("b=%#v", b)
-	client := &http.Client{}
-	return client.Do(req)
-}
+var (
+	backendChosen bool
+	connectOnce   = new(sync.Once)
+)
 
-func addAuthToHeader(hdr *http.Header, s *SlyftAuth) {
-	hdr.Add("access-token", s.AccessToken)
-	hdr.Add("client", s.Client)
-	hdr.Add("uid", s.Uid)
+// connect checks for updates and negotiates the API version before the
+// first request to the server, so commands working offline (like `slyft
+// asset check`) never touch the network.
+func connect() {
+	connectOnce.Do(func() {
+		if err := UpdateCheck(VERSION); err != nil {
+			Log.Error(err)
+			exit(exitCodeOf(err))
+		}
+		if err := NegotiateAPI(backendChosen); err != nil {
+			Log.Error(err)
+			exit(exitCodeOf(err))
+		}
+	})
 }
 
-func ServerURL(endpoint string) string {
-	return BackendBaseUrl + endpoint
+// newClient returns an API client for the configured backend. auth may
+// be nil for unauthenticated requests.
+func newClient(auth *SlyftAuth) *client.Client {
+	connect()
+	c := client.New(BackendBaseUrl, (*client.Auth)(auth))
+	c.HTTPClient = http.DefaultClient
+	c.APIVersion = APIVersion
+	if fRetries != nil {
+		c.Retry.Attempts = *fRetries + 1
+	}
+	c.Logf = Log.Debugf
+	if auth != nil {
+		c.OnAuthUpdate = func(a *client.Auth) { storeRotatedAuth((*SlyftAuth)(a)) }
+	}
+	return c
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SlyftState remembers which assets were synced from a directory, so
+// later syncs can tell files deleted on the server from new files, and
+// which chunked uploads are in progress. It is kept in .slyftstate next
+// to the files.
+type SlyftState struct {
+	Projects map[string]*ProjectState `json:"projects"`
+}
+
+type ProjectState struct {
+	Assets  map[string]*AssetState  `json:"assets"`
+	Uploads map[string]*UploadState `json:"uploads,omitempty"`
+}
+
+type AssetState struct {
+	SyncedAt time.Time `json:"synced_at"`
+	Digest   string    `json:"sha256,omitempty"`
+}
+
+// UploadState identifies an interrupted chunked upload, which is resumed
+// if the file still has the same digest.
+type UploadState struct {
+	ID      string `json:"id"`
+	Digest  string `json:"sha256"`
+	AssetID int    `json:"asset_id,omitempty"`
+}
+
+func stateFile(dir string) string {
+	return filepath.Join(dir, ".slyftstate")
+}
+
+// readState reads the state of dir. A missing or broken state file
+// yields an empty state.
+func readState(dir string) *SlyftState {
+	state := &SlyftState{}
+	content, err := readFile(stateFile(dir))
+	if err == nil {
+		if err := json.Unmarshal(content, state); err != nil {
+			Log.Warningf("Ignoring broken %s: %v", stateFile(dir), err)
+		}
+	}
+	if state.Projects == nil {
+		state.Projects = make(map[string]*ProjectState)
+	}
+	return state
+}
+
+func (s *SlyftState) write(dir string) error {
+	content, err := json.MarshalIndent(s, "", "	")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(stateFile(dir), content, 0644)
+}
+
+func (s *SlyftState) project(id int) *ProjectState {
+	key := strconv.Itoa(id)
+	ps, ok := s.Projects[key]
+	if !ok {
+		ps = &ProjectState{}
+		s.Projects[key] = ps
+	}
+	if ps.Assets == nil {
+		ps.Assets = make(map[string]*AssetState)
+	}
+	if ps.Uploads == nil {
+		ps.Uploads = make(map[string]*UploadState)
+	}
+	return ps
+}
+
+func (ps *ProjectState) synced(name, digest string) {
+	ps.Assets[name] = &AssetState{SyncedAt: time.Now().UTC(), Digest: digest}
+}
+
+func (ps *ProjectState) forget(name string) {
+	delete(ps.Assets, name)
+}
+
+// digest returns the digest recorded for an asset, if any.
+func (ps *ProjectState) digest(name string) string {
+	if as, ok := ps.Assets[name]; ok {
+		return as.Digest
+	}
+	return ""
+}
+
+func (ps *ProjectState) wasSynced(name string) bool {
+	_, ok := ps.Assets[name]
+	return ok
+}
+
+// assetDigest returns the hex encoded SHA-256 of the content of an asset.
+// Preflight does not alter the content, so the digest of a file equals the
+// digest of what was uploaded from it.
+func assetDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func fileDigest(file string) (string, error) {
+	content, err := readFile(file)
+	if err != nil {
+		return "", err
+	}
+	return assetDigest(content), nil
+}
+
+// assetChanged reports whether file differs from the uploaded asset a. The
+// digest recorded at the last upload is authoritative; for assets without
+// one, the content is downloaded and its digest compared.
+func assetChanged(file string, a *Asset, ps *ProjectState) (bool, error) {
+	recorded := ps.digest(a.Name)
+	if recorded == "" {
+		var err error
+		if recorded, err = remoteDigest(a); err != nil {
+			return false, err
+		}
+	}
+	digest, err := fileDigest(file)
+	if err != nil {
+		return false, err
+	}
+	return digest != recorded, nil
+}
+
+// remoteDigest downloads the content of a and returns its assetDigest.
+func remoteDigest(a *Asset) (string, error) {
+	c, err := authClient()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if err := c.DownloadAsset(a.ProjectId, a.Name, h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stateMu serializes updates of the state by concurrent uploads.
+var stateMu sync.Mutex
+
+// updateState applies fn to the state of a project in dir and writes it
+// back. Failing to do so only costs a redundant upload later, so it is
+// not an error.
+func updateState(dir string, projectID int, fn func(ps *ProjectState)) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	state := readState(dir)
+	fn(state.project(projectID))
+	if err := state.write(dir); err != nil {
+		Log.Warningf("Unable to write %s: %v", stateFile(dir), err)
+	}
+}
+
+// recordUpload remembers the digest of an asset uploaded from dir.
+func recordUpload(dir string, projectID int, name, digest string) {
+	updateState(dir, projectID, func(ps *ProjectState) {
+		ps.synced(name, digest)
+	})
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cli "github.com/jawher/mow.cli"
+)
+
+const (
+	syncNew             = "new"
+	syncChanged         = "changed"
+	syncUnchanged       = "unchanged"
+	syncDeletedLocally  = "deleted-locally"
+	syncDeletedRemotely = "deleted-remotely"
+	syncIgnored         = "ignored"
+)
+
+const (
+	syncActionUpload       = "upload"
+	syncActionUpdate       = "update"
+	syncActionDeleteRemote = "delete remote"
+	syncActionDeleteLocal  = "delete local"
+	syncActionSkip         = "skip"
+)
+
+// SyncItem is one entry of the plan to sync a directory with the assets
+// of a project.
+type SyncItem struct {
+	Name   string `json:"name"`
+	Change string `json:"change"`
+	Action string `json:"action"`
+	File   string `json:"-"`
+	Asset  *Asset `json:"-"`
+}
+
+// localAssetFiles returns the asset files below dir, keyed by their
+// slash separated path relative to dir, which is used as asset name.
+func localAssetFiles(dir string) (map[string]string, error) {
+	return walkAssetFiles(dir, &fileFilter{})
+}
+
+// planSync compares the files in dir with the assets of a project. The
+// state of the last sync tells whether a file missing on the server is
+// new or was deleted there. Deletions are only planned with deleteFlag.
+// Assets whose files are hidden, ignored or no asset files are skipped.
+func planSync(dir string, assets []Asset, ps *ProjectState, deleteFlag bool) ([]SyncItem, error) {
+	files, err := localAssetFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := withIgnoreFile(dir, &fileFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	deleteAction := func(action string) string {
+		if deleteFlag {
+			return action
+		}
+		return syncActionSkip
+	}
+
+	plan := make([]SyncItem, 0)
+	remote := make(map[string]bool)
+	for i := range assets {
+		a := &assets[i]
+		remote[a.Name] = true
+		file, ok := files[a.Name]
+		if !ok {
+			// only files the walk would have picked up count as deleted
+			_, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(a.Name)))
+			if !filter.selects(a.Name) || !os.IsNotExist(err) {
+				plan = append(plan, SyncItem{a.Name, syncIgnored, syncActionSkip, "", a})
+				continue
+			}
+			plan = append(plan, SyncItem{a.Name, syncDeletedLocally, deleteAction(syncActionDeleteRemote), "", a})
+			continue
+		}
+		changed, err := assetChanged(file, a, ps)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			plan = append(plan, SyncItem{a.Name, syncChanged, syncActionUpdate, file, a})
+		} else {
+			plan = append(plan, SyncItem{a.Name, syncUnchanged, syncActionSkip, file, a})
+		}
+	}
+
+	for name, file := range files {
+		if remote[name] {
+			continue
+		}
+		if ps.wasSynced(name) {
+			plan = append(plan, SyncItem{name, syncDeletedRemotely, deleteAction(syncActionDeleteLocal), file, nil})
+		} else {
+			plan = append(plan, SyncItem{name, syncNew, syncActionUpload, file, nil})
+		}
+	}
+
+	sort.Slice(plan, func(i, j int) bool { return plan[i].Name < plan[j].Name })
+	return plan, nil
+}
+
+func displaySyncPlan(plan []SyncItem) {
+	if structuredOutput() {
+		displayStructured(plan)
+		return
+	}
+
+	data := [][]string{{"Asset", "Change", "Action"}}
+	for _, item := range plan {
+		data = append(data, []string{item.Name, item.Change, item.Action})
+	}
+	fmt.Fprint(os.Stdout, markdownTable(&data))
+}
+
+func syncPending(plan []SyncItem) int {
+	pending := 0
+	for _, item := range plan {
+		if item.Action != syncActionSkip {
+			pending++
+		}
+	}
+	return pending
+}
+
+// postNamedAsset uploads a new asset and returns the digest of its content.
+func postNamedAsset(item *SyncItem, p *Project) (string, error) {
+	upload, err := readNamedAsset(item.File, item.Name)
+	if err != nil {
+		return "", err
+	}
+	_, err = postAsset(upload, p)
+	return upload.digest, err
+}
+
+func deleteRemoteAsset(a *Asset) error {
+	c, err := authClient()
+	if err != nil {
+		return err
+	}
+	return a.delete(c)
+}
+
+// applySyncItem carries out a single plan entry and records it in ps.
+func applySyncItem(dir string, item *SyncItem, p *Project, ps *ProjectState) error {
+	switch item.Action {
+	case syncActionUpload:
+		if largeAsset(item.File) {
+			_, digest, err := uploadChunked(dir, item.File, item.Name, p, 0)
+			if err != nil {
+				return err
+			}
+			ps.synced(item.Name, digest)
+			break
+		}
+		digest, err := postNamedAsset(item, p)
+		if err != nil {
+			return err
+		}
+		ps.synced(item.Name, digest)
+	case syncActionUpdate:
+		if largeAsset(item.File) {
+			_, digest, err := uploadChunked(dir, item.File, item.Name, p, item.Asset.ID)
+			if err != nil {
+				return err
+			}
+			ps.synced(item.Name, digest)
+			break
+		}
+		upload, err := readNamedAsset(item.File, item.Name)
+		if err != nil {
+			return err
+		}
+		if err := putAsset(item.Asset.ID, upload, p); err != nil {
+			return err
+		}
+		ps.synced(item.Name, upload.digest)
+	case syncActionDeleteRemote:
+		if err := deleteRemoteAsset(item.Asset); err != nil {
+			return err
+		}
+		ps.forget(item.Name)
+	case syncActionDeleteLocal:
+		if err := os.Remove(item.File); err != nil {
+			return err
+		}
+		ps.forget(item.Name)
+	case syncActionSkip:
+		if item.Change == syncUnchanged && ps.digest(item.Name) == "" {
+			digest, err := fileDigest(item.File)
+			if err != nil {
+				return err
+			}
+			ps.synced(item.Name, digest)
+		}
+	}
+	return nil
+}
+
+func syncAssets(cmd *cli.Cmd) {
+	cmd.Spec = "[--project] [--dry-run] [--delete] [--force] [DIR]"
+	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	dryRun := cmd.BoolOpt("dry-run n", false, "Only show what would be done")
+	deleteFlag := cmd.BoolOpt("delete", false, "Delete assets removed locally, and files of assets removed on the server")
+	force := cmd.BoolOpt("force f", false, "If set, do not ask before applying the changes (default: false)")
+	dir := cmd.StringArg("DIR", ".", "Directory holding the asset files")
+
+	cmd.Action = action(func() error {
+		*name = strings.TrimSpace(*name)
+		if *name == "" {
+			*name, _ = ReadProjectLock()
+		}
+		p, err := chooseProject(*name, "Sync assets of: ")
+		if err != nil {
+			return ReportError("Choosing the project", err)
+		}
+
+		assets, err := fetchAssets(p.ID)
+		if err != nil {
+			return ReportError("Listing the assets", err)
+		}
+
+		state := readState(*dir)
+		ps := state.project(p.ID)
+		plan, err := planSync(*dir, assets, ps, *deleteFlag)
+		if err != nil {
+			return ReportError("Reading "+*dir, err)
+		}
+		displaySyncPlan(plan)
+
+		pending := syncPending(plan)
+		if pending == 0 {
+			statusf("Everything is in sync.\n")
+		}
+		if *dryRun || pending == 0 {
+			return nil
+		}
+		if !*force && !askForConfirmation(fmt.Sprintf("Apply %d change(s) to project %s?", pending, p.Name)) {
+			return nil
+		}
+
+		failed := 0
+		var first error
+		for i := range plan {
+			item := &plan[i]
+			if item.Action != syncActionSkip {
+				statusf("%s %s\n", item.Action, item.Name)
+			}
+			if err := applySyncItem(*dir, item, p, ps); err != nil {
+				ReportError(fmt.Sprintf("%s %s", item.Action, item.Name), err)
+				if failed++; first == nil {
+					first = err
+				}
+			}
+		}
+
+		// chunked uploads keep their progress in the state meanwhile
+		updateState(*dir, p.ID, func(current *ProjectState) {
+			current.Assets = ps.Assets
+		})
+		if failed > 0 {
+			statusf("Sync finished, %d of %d change(s) failed.\n", failed, pending)
+		} else {
+			statusf("Sync finished, %d change(s) applied.\n", pending)
+		}
+		return first
+	})
+}
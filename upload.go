@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/thingforward/slyft-cli/client"
+)
+
+// largeAsset reports whether file is too large to be uploaded as data
+// URI, so it has to be uploaded in chunks.
+func largeAsset(file string) bool {
+	fi, err := os.Stat(file)
+	return err == nil && fi.Size() > int64(maxAssetLen)
+}
+
+// pendingUpload returns the interrupted upload of name from dir, if
+// there is one for the same content and target asset.
+func pendingUpload(c *client.Client, dir string, projectID int, name, digest string, assetID int) *client.Upload {
+	var pending *UploadState
+	updateState(dir, projectID, func(ps *ProjectState) {
+		pending = ps.Uploads[name]
+		if pending != nil && (pending.Digest != digest || pending.AssetID != assetID) {
+			// the file changed meanwhile, start over
+			delete(ps.Uploads, name)
+			pending = nil
+		}
+	})
+	if pending == nil {
+		return nil
+	}
+	u, err := c.GetUpload(projectID, pending.ID)
+	if err != nil {
+		Log.Debugf("Unable to resume upload %s of %s: %v", pending.ID, name, err)
+		return nil
+	}
+	return u
+}
+
+// uploadChunked uploads file as asset called name in chunks, replacing
+// the content of the asset with ID replaceID, or creating a new asset if
+// it is 0. The upload is kept in the state of dir until it is complete,
+// so it is resumed if interrupted. It returns the asset and the digest
+// of its content. If a new asset's name is taken, the existing asset is
+// returned with an error for which client.IsConflict is true.
+func uploadChunked(dir, file, name string, p *Project, replaceID int) (*Asset, string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	mimeType, err := preflightFile(f, file)
+	if err != nil {
+		return nil, "", err
+	}
+	size, digest, err := readerDigest(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c, err := authClient()
+	if err != nil {
+		return nil, "", err
+	}
+
+	u := pendingUpload(c, dir, p.ID, name, digest, replaceID)
+	if u != nil {
+		statusf("Resuming upload of %s at %d of %d bytes\n", name, u.Offset, u.Size)
+	} else {
+		u, err = c.CreateUpload(p.ID, name, mimeType, size, digest, replaceID)
+		if err == client.ErrUploadsUnsupported {
+			return nil, "", newError(exitValidation, "%s exceeds %d bytes, and %v", file, maxAssetLen, err)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		updateState(dir, p.ID, func(ps *ProjectState) {
+			ps.Uploads[name] = &UploadState{u.ID, digest, replaceID}
+		})
+	}
+
+	a, err := c.UploadFrom(p.ID, u, f, func(u *client.Upload) {
+		Log.Debugf("Uploaded %d of %d bytes of %s", u.Offset, u.Size, name)
+	})
+	if err == nil || client.IsConflict(err) {
+		updateState(dir, p.ID, func(ps *ProjectState) {
+			delete(ps.Uploads, name)
+		})
+	}
+	return (*Asset)(a), digest, err
+}
+
+// preflightFile is preflightContent for the content of f. Parsing needs
+// the whole document, but it is dropped again before uploading.
+func preflightFile(f *os.File, file string) (string, error) {
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return preflightContent(content, file)
+}
+
+// readerDigest returns the size and assetDigest of the content of r,
+// read from the start.
+func readerDigest(r io.ReadSeeker) (int64, string, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, "", err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readFileAndPostLargeAsset is readFileAndPostNamedAsset for large files.
+func readFileAndPostLargeAsset(dir, file, name string, p *Project, forceFlag bool) error {
+	a, digest, err := uploadChunked(dir, file, name, p, 0)
+	if client.IsConflict(err) && a != nil {
+		if !forceFlag && !askForConfirmation("The asset already exists. Do you want to overwrite it?") {
+			return nil
+		}
+		a, digest, err = uploadChunked(dir, file, name, p, a.ID)
+	}
+	if err != nil {
+		return ReportError("Uploading asset", err)
+	}
+
+	if !structuredOutput() {
+		a.Display()
+	}
+	recordUpload(dir, p.ID, name, digest)
+	return nil
+}
@@ -2,9 +2,7 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -15,19 +13,12 @@ import (
 	"time"
 
 	"github.com/jawher/mow.cli"
+	"github.com/thingforward/slyft-cli/client"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
-type SlyftAuth struct {
-	AccessToken string `json:"access_token"`
-	Client      string `json:"client"`
-	Uid         string `json:"uid"`
-}
-
-type SlyftAuthResult struct {
-	Success bool
-	Errors  []string
-}
+// SlyftAuth is a client.Auth as stored in the credential stores.
+type SlyftAuth client.Auth
 
 func (sa SlyftAuth) String() string {
 	bytes, err := json.Marshal(sa)
@@ -41,8 +32,24 @@ func (sa SlyftAuth) GoodForLogin() bool {
 	return sa.AccessToken != "" && sa.Client != "" && sa.Uid != ""
 }
 
+// ExpiresAfter reports whether sa is valid longer than other.
+func (sa SlyftAuth) ExpiresAfter(other *SlyftAuth) bool {
+	return client.Auth(sa).ExpiresAfter((*client.Auth)(other))
+}
+
 type SlyftRC struct {
-	Auth SlyftAuth
+	// Auth is only read from configs written before profiles existed
+	Auth     *SlyftAuth               `json:",omitempty"`
+	Current  string                   `json:"current_profile,omitempty"`
+	Profiles map[string]*SlyftProfile `json:"profiles,omitempty"`
+
+	// CredentialStore selects where auth tokens are kept, see credentials.go
+	CredentialStore string `json:"credential_store,omitempty"`
+
+	UpdateCheck *UpdateCheckConfig `json:"update_check,omitempty"`
+
+	// JobKinds are offered by `slyft job run` if the server doesn't list them
+	JobKinds []JobKind `json:"job_kinds,omitempty"`
 }
 
 func (sr SlyftRC) String() string {
@@ -53,29 +60,6 @@ func (sr SlyftRC) String() string {
 	return string(bytes)
 }
 
-type Credentials struct {
-	Email                string          `json:"email"`
-	Password             string          `json:"password"`
-	PasswordConfirmation string          `json:"password_confirmation"`
-	TermsAcceptance      TermsAcceptance `json:"terms"`
-}
-
-type Terms struct {
-	Url       string `json:"url"`
-	StartedAt string `json:"started_at"`
-}
-
-type TermsAcceptance struct {
-	Accepted  bool   `json:"accepted"`
-	Timestamp string `json:"timestamp"`
-}
-
-type PwdResetRequest struct {
-	Code                 string `json:"code,omitempty"`
-	Password             string `json:"password"`
-	PasswordConfirmation string `json:"password_confirmation"`
-}
-
 func readSecret(ask string) string {
 	pwd_from_env := os.Getenv("SLYFT_USER_REGISTRATION_PWD")
 	if len(pwd_from_env) == 0 {
@@ -96,19 +80,10 @@ func readSecret(ask string) string {
 
 func termsUri() (string, error) {
 	// get T&C JSON from endpoint to get the URL to the latest terms document
-	resp, err := DoNoAuth("/terms", "GET", nil)
-	defer resp.Body.Close()
+	t, err := newClient(nil).GetTerms()
 	if err != nil {
 		return "", err
 	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	t := &Terms{}
-	if err := json.Unmarshal(body, &t); err != nil {
-		return "", err
-	}
 	return t.Url, nil
 }
 
@@ -214,25 +189,19 @@ func validateEmail(s string) bool {
 	return re.FindStringIndex(s) != nil
 }
 
-func getCredentials(confirm bool) *Credentials {
+func getCredentials(confirm bool) *client.Credentials {
 	email, password, confirmation := readCredentials(confirm)
-	return &Credentials{
+	return &client.Credentials{
 		Email:                email,
 		Password:             password,
 		PasswordConfirmation: confirmation,
 	}
 }
 
-func extractAuthFromHeader(hdr *http.Header) SlyftAuth {
-	return SlyftAuth{
-		AccessToken: hdr.Get("access-token"),
-		Client:      hdr.Get("client"),
-		Uid:         hdr.Get("uid"),
+func authenticateUser(register bool) error {
+	if !interactive() {
+		return newError(exitUsage, "Credentials can only be entered on an interactive terminal")
 	}
-}
-
-func authenticateUser(endpoint string, register bool) error {
-	url := ServerURL(endpoint)
 	creds := getCredentials(register)
 	// if the user wants to register, show T&C to the user, and ask for acceptance
 	if register {
@@ -243,77 +212,51 @@ func authenticateUser(endpoint string, register bool) error {
 
 		accept, err := acceptTermsAndConditions()
 		if !accept {
-			return errors.New(fmt.Sprintf("You need to accept the terms first. %v\n", err))
+			return newError(exitUsage, "You need to accept the terms first. %v\n", err)
 		}
 		creds.TermsAcceptance.Accepted = accept
 		creds.TermsAcceptance.Timestamp = time.Now().UTC().Format("2006-01-02T15:04:05-0700")
 	}
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(creds)
-
-	Log.Debugf("url=%#v", url)
-
-	resp, err := http.Post(url, "application/json; charset=utf-8", b)
 
-	Log.Debugf("err=%#v", err)
-	Log.Debugf("resp=%#v", resp)
-
-	if err != nil {
-		return err
+	c := newClient(nil)
+	var auth *client.Auth
+	var err error
+	if register {
+		auth, err = c.Register(creds)
+	} else {
+		auth, err = c.SignIn(creds)
 	}
-	defer resp.Body.Close()
-
-	// Verify if the resp was ok
-	if resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusOK {
-		slyftAuth := extractAuthFromHeader(&resp.Header)
-		return writeAuthToConfig(&slyftAuth)
+	if err == nil {
+		return writeAuthToConfig((*SlyftAuth)(auth))
 	}
 
 	// handle the error
-	body, err := ioutil.ReadAll(resp.Body)
-	if err == nil {
+	if e, ok := err.(*client.Error); ok {
 		if register {
 			fmt.Print("\nWe're sorry, but your registration failed due to the following errors:\n")
-			var f interface{}
-			err := json.Unmarshal(body, &f)
-			if err == nil {
-				m := f.(map[string]interface{})
-				e := (m["errors"]).(map[string]interface{})
-				fm := (e["full_messages"]).([]interface{})
-				for _, msg := range fm {
-					fmt.Printf("* %s\n", msg)
-				}
-
-			}
 		} else {
 			fmt.Print("\nWe're sorry, but your login failed due to the following errors:\n")
-			var sar SlyftAuthResult
-			err := json.Unmarshal(body, &sar)
-			if err == nil {
-				for _, msg := range sar.Errors {
-					fmt.Printf("* %s\n", msg)
-				}
-			} else {
-				// Unable to parse it, log as-is
-				Log.Critical(string(body))
-			}
 		}
-		return errors.New(fmt.Sprintf("Server returned failure: %v\nBye", resp.Status))
-	} else {
-		Log.Critical("Error reading/parsing API output.")
+		for _, msg := range e.Messages {
+			fmt.Printf("* %s\n", msg)
+		}
+		if len(e.Messages) == 0 {
+			// Unable to parse it, log as-is
+			Log.Critical(string(e.Body))
+		}
 	}
-
-	return errors.New(fmt.Sprintf("Reading server resonse failed: %v\n", err))
+	return err
 }
 
-func RegisterUser() {
+func RegisterUser() error {
 	fmt.Println("\nThank you for your interest in Slyft! Please provide us your email address and")
 	fmt.Println("a password (min. 6 characters). Please make sure you have access to the email account given")
 	fmt.Println("as we will send you a confirmation email to this address.")
 	fmt.Println()
-	err := authenticateUser("/auth", true)
+	err := authenticateUser(true)
 	if err != nil {
 		fmt.Println("We're very sorry, but your registration failed.")
+		return err
 	} else {
 		fmt.Println("\nRegistration successful. We've sent you a confirmation email to the email address")
 		fmt.Println("you given for this registration process. Please have a look at your inbox for")
@@ -321,65 +264,45 @@ func RegisterUser() {
 		fmt.Println("to activate your account.")
 		fmt.Println()
 	}
+	return nil
 }
 
-func LogUserIn() {
-	err := authenticateUser("/auth/sign_in", false)
+func LogUserIn() error {
+	err := authenticateUser(false)
 	if err != nil {
 		fmt.Println("Sorry, login failed")
-	} else {
-		fmt.Println("Login successful, have fun! For documentation, please have a look at www.slyft.io/docs")
-	}
-}
-
-func makeDeleteCall(endpoint string) error {
-	resp, err := Do(endpoint, "DELETE", nil)
-	deactivateLogin()
-
-	Log.Debugf("err=%#v", err)
-	Log.Debugf("resp=%#v", resp)
-
-	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	// Verify if the resp was ok
-	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusOK {
-		slyftAuth := extractAuthFromHeader(&resp.Header)
-		return writeAuthToConfig(&slyftAuth)
-	}
+	fmt.Println("Login successful, have fun! For documentation, please have a look at www.slyft.io/docs")
+	return nil
+}
 
-	// handle the error
-	body, err := ioutil.ReadAll(resp.Body)
-	Log.Debugf("err=%#v", err)
-	Log.Debugf("body=%#v", body)
+// endSession ends the session of the stored credentials on the server
+// with end, e.g. by signing out, and forgets them in any case.
+func endSession(end func(c *client.Client) error) error {
+	c, err := authClient()
 	if err == nil {
-		Log.Critical(string(body)) // TODO -- parse and print it beautifully. Extract errors/full_messages/etc.
-		return errors.New(fmt.Sprintf("Server returned failure: %v\nBye", resp.Status))
+		err = end(c)
 	}
-
-	return errors.New(fmt.Sprintf("Reading server resonse failed: %v\n", err))
+	deactivateLogin()
+	return err
 }
 
-func LogUserOut() {
-	err := makeDeleteCall("/auth/sign_out")
+func LogUserOut() error {
+	err := endSession((*client.Client).SignOut)
 	if err != nil {
 		Log.Error("Sorry, logout failed.")
-	} else {
-		fmt.Println("Bye for now. Looking forward to seeing you soon...")
+		return err
 	}
+	fmt.Println("Bye for now. Looking forward to seeing you soon...")
+	return nil
 }
 
-func DeleteUser() {
+func DeleteUser() error {
 	auth, err := readAuthFromConfig()
-	if err != nil {
-		fmt.Println("You do not seem to be logged in. Please do a `slyft user login`")
-		return
-	}
-	if !auth.GoodForLogin() {
+	if err != nil || !auth.GoodForLogin() {
 		fmt.Println("You do not seem to be logged in. Please do a `slyft user login`")
-		return
+		return newError(exitAuth, "Not logged in.")
 	}
 
 	fmt.Println("You may choose to delete your Slyft account at any time. Please be aware")
@@ -388,23 +311,20 @@ func DeleteUser() {
 
 	confirm := askForConfirmation("Are you sure to delete your user account?")
 	if confirm {
-		err := makeDeleteCall("/auth")
+		err := endSession((*client.Client).DeleteAccount)
 		if err != nil {
 			Log.Error("Sorry, deletion failed")
-		} else {
-			fmt.Println("Deleted the account. We are sorry to see you go. Come back soon...")
+			return err
 		}
+		fmt.Println("Deleted the account. We are sorry to see you go. Come back soon...")
 	} else {
 		fmt.Println("Account left unchanged.")
 	}
+	return nil
 }
 
-func ForgotPassword() {
+func ForgotPassword() error {
 	var email string
-	type forgotPwd struct {
-		Email       string `json:"email"`
-		RedirectUrl string `json:"redirect_url"`
-	}
 	auth, err := readAuthFromConfig()
 	if err != nil || !auth.GoodForLogin() {
 		fmt.Print("Please provide the email address you have used to register: ")
@@ -412,7 +332,7 @@ func ForgotPassword() {
 		email = strings.TrimSpace(email)
 		if !validateEmail(email) {
 			fmt.Println("Not a valid email address. Please try again.")
-			return
+			return newError(exitUsage, "Not a valid email address.")
 		}
 	} else {
 		email = auth.Uid
@@ -421,23 +341,19 @@ func ForgotPassword() {
 	fmt.Printf("We will send an email to %s containing a reset code.\nThen use the change password function to reset your password using the token. ", email)
 	cont := askForConfirmation("continue?")
 	if !cont {
-		return
+		return nil
 	}
 
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(&forgotPwd{Email: email, RedirectUrl: ""})
-
-	resp, err := http.Post(ServerURL("/auth/password"), "application/json; charset=utf-8", b)
-	Log.Debugf("resp=%#v", resp)
-	if err != nil || resp.StatusCode != http.StatusOK {
+	if err := newClient(nil).RequestPasswordReset(email); err != nil {
 		Log.Debugf("err=%#v", err)
 		fmt.Println("Sorry, password could not be reset. Please try again")
-		return
+		return err
 	}
 	fmt.Println("Check your inbox for an email with the token, then use the change password fucntion")
+	return nil
 }
 
-func ChangePassword() {
+func ChangePassword() error {
 	var email string
 	auth, err := readAuthFromConfig()
 	var changePasswordForLoggedInUser bool
@@ -447,7 +363,7 @@ func ChangePassword() {
 		email = strings.TrimSpace(email)
 		if !validateEmail(email) {
 			fmt.Println("Not a valid email address. Please try again.")
-			return
+			return newError(exitUsage, "Not a valid email address.")
 		}
 	} else {
 		email = auth.Uid
@@ -458,7 +374,7 @@ func ChangePassword() {
 	resetRequest, err := askUserForNewPasswordAndConfirmation()
 	if err != nil {
 		fmt.Printf("invalid input: %s\n", err)
-		return
+		return &Error{exitUsage, err}
 	}
 
 	resetWithToken := askForConfirmation("If you have a reset token, answer (y)")
@@ -473,7 +389,7 @@ func ChangePassword() {
 	}
 	if err != nil {
 		fmt.Printf("Could not reset password: %s\n", err)
-		return
+		return err
 	}
 
 	fmt.Println("The password has been reset for account: ", email)
@@ -481,9 +397,10 @@ func ChangePassword() {
 		deactivateLogin() // We leave it to the server whether to clean up the tokens.
 	}
 	fmt.Println("Please login with your new credentials.")
+	return nil
 }
 
-func askUserForNewPasswordAndConfirmation() (*PwdResetRequest, error) {
+func askUserForNewPasswordAndConfirmation() (*client.PasswordReset, error) {
 	password := readSecret("Please provide your new password (min. 6 characters): ")
 	if !validatePassword(password) {
 		err := fmt.Errorf("Not a valid password. Please try again.")
@@ -496,54 +413,45 @@ func askUserForNewPasswordAndConfirmation() (*PwdResetRequest, error) {
 		err := fmt.Errorf("Passwords do not match. Please try again.")
 		return nil, err
 	}
-	return &PwdResetRequest{Password: password, PasswordConfirmation: passwordConfirmation}, nil
+	return &client.PasswordReset{Password: password, PasswordConfirmation: passwordConfirmation}, nil
 }
 
-func updatePasswordWithResetToken(email string, resetRequest *PwdResetRequest) error {
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(resetRequest)
-
-	resp, err := http.Post(ServerURL("/password_reset"), "application/json; charset=utf-8", b)
-	Log.Debugf("resp=%#v", resp)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		Log.Debugf("err=%#v", err)
-		return fmt.Errorf("Sorry, password could not be reset. Please try again")
+func updatePasswordWithResetToken(email string, resetRequest *client.PasswordReset) error {
+	err := newClient(nil).ResetPassword(resetRequest)
+	if e, ok := err.(*client.Error); ok {
+		return statusError(e.StatusCode, "Sorry, password could not be reset. Please try again")
 	}
-	return nil
+	return err
 }
 
-func updatePasswordForAuthenticatedUser(email string, resetRequest *PwdResetRequest) error {
+func updatePasswordForAuthenticatedUser(email string, resetRequest *client.PasswordReset) error {
 	password := readSecret("Please provide your current password: ")
 	password = strings.TrimSpace(password)
 
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(&Credentials{Email: email, Password: password})
-
-	resp, err := http.Post(ServerURL("/auth/sign_in"), "application/json; charset=utf-8", b)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Sorry, the credentials are not correct. Please try again")
+	newAuth, err := newClient(nil).SignIn(&client.Credentials{Email: email, Password: password})
+	if e, ok := err.(*client.Error); ok {
+		return statusError(e.StatusCode, "Sorry, the credentials are not correct. Please try again")
+	}
+	if err != nil {
+		return err
 	}
 
-	newAuth := extractAuthFromHeader(&resp.Header)
-
-	resp, err = DoAuth("/auth/password", "PUT", resetRequest, &newAuth)
-	Log.Debugf("resp=%#v", resp)
-
-	if err != nil || (resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent) {
-		Log.Debugf("err=%#v", err)
-		Log.Debugf("err=%#v", resp)
-		return fmt.Errorf("Sorry, changing password failed.")
+	err = newClient((*SlyftAuth)(newAuth)).ChangePassword(resetRequest)
+	if e, ok := err.(*client.Error); ok {
+		Log.Debugf("err=%#v", e)
+		return statusError(e.StatusCode, "Sorry, changing password failed.")
 	}
-	return nil
+	return err
 }
 
 func RegisterUserRoutes(user *cli.Cmd) {
 	SetupLogger()
 
-	user.Command("register r", "Register yourself", func(cmd *cli.Cmd) { cmd.Action = RegisterUser })
-	user.Command("login l", "Login with your credentials", func(cmd *cli.Cmd) { cmd.Action = LogUserIn })
-	user.Command("logout", "Log out from your session", func(cmd *cli.Cmd) { cmd.Action = LogUserOut })
-	user.Command("delete", "Delete your account", func(cmd *cli.Cmd) { cmd.Action = DeleteUser })
-	user.Command("change-password cp", "Change your password", func(cmd *cli.Cmd) { cmd.Action = ChangePassword })
-	user.Command("forgot-password fp", "Request password reset token, forgot password function", func(cmd *cli.Cmd) { cmd.Action = ForgotPassword })
+	user.Command("register r", "Register yourself", func(cmd *cli.Cmd) { cmd.Action = action(RegisterUser) })
+	user.Command("login l", "Login with your credentials", func(cmd *cli.Cmd) { cmd.Action = action(LogUserIn) })
+	user.Command("logout", "Log out from your session", func(cmd *cli.Cmd) { cmd.Action = action(LogUserOut) })
+	user.Command("delete", "Delete your account", func(cmd *cli.Cmd) { cmd.Action = action(DeleteUser) })
+	user.Command("change-password cp", "Change your password", func(cmd *cli.Cmd) { cmd.Action = action(ChangePassword) })
+	user.Command("forgot-password fp", "Request password reset token, forgot password function", func(cmd *cli.Cmd) { cmd.Action = action(ForgotPassword) })
+	user.Command("profiles pr", "Manage profiles for different backends/accounts", RegisterProfileRoutes)
 }
@@ -2,9 +2,11 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -12,13 +14,61 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/jawher/mow.cli"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
+// exit ends slyft with the given code. Tests replace it to observe the
+// code.
+var exit = cli.Exit
+
+var fNonInteractive *bool
+var fYes *bool
+
+// interactive reports whether the user may be prompted on stdin. This is
+// not the case with --non-interactive or --yes, or if stdin is no terminal.
+func interactive() bool {
+	if fNonInteractive != nil && *fNonInteractive {
+		return false
+	}
+	if fYes != nil && *fYes {
+		return false
+	}
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// ambiguousChoice is returned instead of prompting when a selection
+// matches more than one candidate in non-interactive mode.
+func ambiguousChoice(what string, candidates []string) error {
+	return newError(exitUsage, "More than one %s matches, please be more specific. Candidates:\n  %s",
+		what, strings.Join(candidates, "\n  "))
+}
+
+// promptMu keeps concurrent workers from prompting at the same time.
+var promptMu sync.Mutex
+
 func askForConfirmation(s string) bool {
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	if !interactive() {
+		// --yes answers every confirmation, otherwise play safe
+		answer := fYes != nil && *fYes
+		if answer {
+			fmt.Fprintf(os.Stderr, "%s [y/n]: y (--yes)\n", s)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s [y/n]: n (non-interactive, use --yes to confirm)\n", s)
+		}
+		return answer
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 
 	for {
-		fmt.Printf("%s [y/n]: ", s)
+		fmt.Fprintf(os.Stderr, "%s [y/n]: ", s)
 
 		response, err := reader.ReadString('\n')
 		if err != nil {
@@ -36,6 +86,76 @@ func askForConfirmation(s string) bool {
 	}
 }
 
+// fileResult is the outcome of processing a single file.
+type fileResult struct {
+	File  string `json:"file"`
+	Error string `json:"error,omitempty"`
+
+	err error
+}
+
+// forEachFile calls fn for every file, running up to parallel calls at a
+// time, and returns the results in the order of files.
+func forEachFile(files []string, parallel int, fn func(file string) error) []fileResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]fileResult, len(files))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel && w < len(files); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i].File = files[i]
+				if err := fn(files[i]); err != nil {
+					results[i].Error = err.Error()
+					results[i].err = err
+				}
+			}
+		}()
+	}
+	for i := range files {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// displayFileResults summarizes the results of forEachFile. If files
+// failed, it returns an error of the category of the first one.
+func displayFileResults(results []fileResult) error {
+	failed := 0
+	var first error
+	data := [][]string{{"File", "Result"}}
+	for _, r := range results {
+		result := "ok"
+		if r.Error != "" {
+			result = r.Error
+			failed++
+			if first == nil {
+				first = r.err
+			}
+		}
+		data = append(data, []string{r.File, result})
+	}
+
+	if structuredOutput() {
+		displayStructured(results)
+	} else if len(results) > 1 {
+		fmt.Fprint(os.Stdout, markdownTable(&data))
+	}
+	if failed == 0 {
+		return nil
+	}
+	if !structuredOutput() {
+		fmt.Printf("%d of %d file(s) failed.\n", failed, len(results))
+	}
+	return &Error{exitCodeOf(first), fmt.Errorf("%d of %d file(s) failed", failed, len(results))}
+}
+
 func portableGetUsersHome() string {
 	// works on Linux, OSX, Windows cmd and Windows gitbash
 	home := os.Getenv("HOME")
@@ -83,17 +203,111 @@ func readConfig() (*SlyftRC, error) {
 }
 
 func writeAuthToConfig(sa *SlyftAuth) error {
-	sr, _ := readConfig()
-	// note -- we are ignoring the error here.
+	return updateConfig(func(sr *SlyftRC) error {
+		store, err := credentialStore(sr)
+		if err != nil {
+			return err
+		}
+		name := currentProfileName(sr)
+		if err := store.Save(name, sa); err != nil {
+			Log.Error("Failure to store credentials: " + err.Error())
+			return err
+		}
+		// remember an explicitly chosen backend for this profile
+		if backend := os.Getenv("SLYFTBACKEND"); backend != "" {
+			sr.profile(name).Backend = backend
+		}
+		return nil
+	})
+}
+
+// storeRotatedAuth persists an access token the server handed out in
+// exchange for the current one. Another slyft process may have stored a
+// fresher token in the meantime, so tokens expiring earlier are dropped.
+func storeRotatedAuth(sa *SlyftAuth) {
+	err := updateConfig(func(sr *SlyftRC) error {
+		store, err := credentialStore(sr)
+		if err != nil {
+			return err
+		}
+		name := currentProfileName(sr)
+		stored, err := store.Load(name)
+		if err != nil {
+			return err
+		}
+		if stored.Uid != sa.Uid || stored.Client != sa.Client {
+			// logged out or in as someone else meanwhile
+			return nil
+		}
+		if stored.ExpiresAfter(sa) {
+			Log.Debugf("Keeping stored token, it expires after the rotated one")
+			return nil
+		}
+		return store.Save(name, sa)
+	})
+	if err != nil {
+		Log.Warningf("Unable to store the renewed access token: %v", err)
+	}
+}
+
+// updateConfig applies fn to the config and writes it back. The config
+// is locked meanwhile, so concurrent invocations of slyft don't lose
+// each other's changes.
+func updateConfig(fn func(sr *SlyftRC) error) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// a missing config is created, but one that can't be read or parsed
+	// is never replaced, that would lose all profiles
+	sr, err := readConfig()
+	if err != nil && !os.IsNotExist(err) {
+		return newError(exitFailure, "Unable to update %s, please fix or remove it: %v", defaultConfigFile(), err)
+	}
+
+	if err := fn(sr); err != nil {
+		return err
+	}
+	sr.removePlaintextAuth()
+	return writeConfig(sr)
+}
 
-	sr.Auth = *sa
+// lockConfig creates ~/.slyftrc.lock exclusively and returns a function
+// to release it. Locks left by crashed processes are removed after a while.
+func lockConfig() (func(), error) {
+	lockFile := defaultConfigFile() + ".lock"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockFile) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(lockFile); err == nil && time.Since(info.ModTime()) > 10*time.Second {
+			Log.Debugf("Removing stale lock %s", lockFile)
+			os.Remove(lockFile)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("Timed out waiting for " + lockFile)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func writeConfig(sr *SlyftRC) error {
 	newConfig, err := json.MarshalIndent(sr, "", "	")
 	if err != nil {
 		Log.Error("Failure to update config file: " + defaultConfigFile())
 		return err
 	}
 
-	err = ioutil.WriteFile(defaultConfigFile(), newConfig, 0640)
+	err = writeFileAtomic(defaultConfigFile(), newConfig, 0600)
 	if err != nil {
 		Log.Error("Failure to write config file: " + defaultConfigFile())
 		return err
@@ -101,14 +315,62 @@ func writeAuthToConfig(sa *SlyftAuth) error {
 	return nil
 }
 
+// writeFileAtomic writes data to a temporary file next to fileName and
+// renames it, so readers never see a partially written file.
+func writeFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	return writeAtomic(fileName, bytes.NewReader(data), perm, nil)
+}
+
+// writeAtomic is writeFileAtomic for content read from r. If commit is
+// set, it is called once all content was written, and fileName is only
+// replaced if it returns nil.
+func writeAtomic(fileName string, r io.Reader, perm os.FileMode, commit func() error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(fileName), "."+filepath.Base(fileName)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if commit != nil {
+		if err := commit(); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
+
 func readAuthFromConfig() (*SlyftAuth, error) {
 	sr, err := readConfig()
 	if err != nil {
 		Log.Error("You don't seem to be logged in. Failed to read your config: " + err.Error())
 		return nil, err
 	}
+	if sr.hasPlaintextAuth() {
+		if err := updateConfig(func(*SlyftRC) error { return nil }); err != nil {
+			Log.Warningf("Unable to remove the tokens from %s: %v", defaultConfigFile(), err)
+		} else if sr, err = readConfig(); err != nil {
+			return nil, err
+		}
+	}
 
-	return &sr.Auth, nil
+	store, err := credentialStore(sr)
+	if err != nil {
+		return nil, err
+	}
+	return store.Load(currentProfileName(sr))
 }
 
 func deactivateLogin() {
@@ -179,10 +441,15 @@ func ReadProjectLock() (string, error) {
 	return "", errors.New("NoProjectLock")
 }
 
-func ReportError(context string, err error) {
-	fmt.Printf("%s: failed.\n", context)
+// ReportError prints errors to stderr, so stdout stays parseable with
+// --output json or yaml, and returns err for the command to fail with.
+func ReportError(context string, err error) error {
+	fmt.Fprintf(os.Stderr, "%s: failed.\n", context)
 	if err != nil {
-		fmt.Printf("Details: %s\n", err.Error())
+		fmt.Fprintf(os.Stderr, "Details: %s\n", err.Error())
 		Log.Debugf("%s - failed - %s\n", context, err)
+	} else {
+		err = errors.New(context + " failed")
 	}
+	return err
 }
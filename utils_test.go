@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAskForConfirmationNonInteractive(t *testing.T) {
+	yes, nonInteractive := false, true
+	fYes, fNonInteractive = &yes, &nonInteractive
+	defer func() { fYes, fNonInteractive = nil, nil }()
+
+	if askForConfirmation("Delete?") {
+		t.Error("Must not confirm in non-interactive mode without --yes")
+	}
+
+	yes = true
+	if !askForConfirmation("Delete?") {
+		t.Error("Must confirm with --yes")
+	}
+}
+
+func TestAmbiguousChoice(t *testing.T) {
+	err := ambiguousChoice("project", []string{"foo", "foobar"})
+	if err == nil || !strings.Contains(err.Error(), "foo\n  foobar") {
+		t.Errorf("Must list candidates, got %v", err)
+	}
+}
+
+func TestStoreRotatedAuth(t *testing.T) {
+	_, cleanup := tempHome(t)
+	defer cleanup()
+
+	current := &SlyftAuth{AccessToken: "b", Client: "client", Uid: "foo@bar.boo", Expiry: "200"}
+	if err := writeAuthToConfig(current); err != nil {
+		t.Fatalf("Must write config: %v", err)
+	}
+
+	// a concurrent process stored a fresher token already
+	storeRotatedAuth(&SlyftAuth{AccessToken: "a", Client: "client", Uid: "foo@bar.boo", Expiry: "100"})
+	if auth, _ := readAuthFromConfig(); auth.AccessToken != "b" {
+		t.Errorf("Must keep the fresher token, got %#v", auth)
+	}
+
+	storeRotatedAuth(&SlyftAuth{AccessToken: "c", Client: "client", Uid: "foo@bar.boo", Expiry: "300"})
+	if auth, _ := readAuthFromConfig(); auth.AccessToken != "c" {
+		t.Errorf("Must store the rotated token, got %#v", auth)
+	}
+
+	if info, err := os.Stat(defaultConfigFile()); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Config must be private, got %v (%v)", info.Mode(), err)
+	}
+}
+
+func TestUpdateConfigKeepsBrokenConfig(t *testing.T) {
+	_, cleanup := tempHome(t)
+	defer cleanup()
+
+	broken := []byte(`{"profiles": {"work": {"auth": `)
+	if err := ioutil.WriteFile(defaultConfigFile(), broken, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeAuthToConfig(&SlyftAuth{AccessToken: "a", Client: "client", Uid: "foo@bar.boo"}); err == nil {
+		t.Error("Must not update a config that can't be parsed")
+	}
+	storeRotatedAuth(&SlyftAuth{AccessToken: "b", Client: "client", Uid: "foo@bar.boo"})
+	if content, _ := ioutil.ReadFile(defaultConfigFile()); string(content) != string(broken) {
+		t.Errorf("Must keep the broken config, got %s", content)
+	}
+}
+
+// tempHome points HOME to a new temporary directory until the returned
+// function is called.
+func tempHome(t *testing.T) (string, func()) {
+	home, err := ioutil.TempDir("", "slyft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	return home, func() {
+		os.Setenv("HOME", oldHome)
+		os.RemoveAll(home)
+	}
+}
+
+func TestForEachFile(t *testing.T) {
+	files := []string{"a.json", "b.json", "c.json", "d.json", "e.json"}
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	results := forEachFile(files, 2, func(file string) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if file == "c.json" {
+			return errors.New("broken")
+		}
+		return nil
+	})
+
+	if maxRunning > 2 {
+		t.Errorf("Must not run more than 2 at a time, ran %d", maxRunning)
+	}
+	for i, r := range results {
+		if r.File != files[i] {
+			t.Errorf("Must keep the order of files, got %s at %d", r.File, i)
+		}
+		if (r.Error != "") != (r.File == "c.json") {
+			t.Errorf("Unexpected result for %s: %q", r.File, r.Error)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// openAPIValidator checks Swagger 2.0 and OpenAPI 3.x specifications.
+type openAPIValidator struct{}
+
+var (
+	reOpenAPI3     = regexp.MustCompile(`^3\.\d+\.\d+$`)
+	rePathTemplate = regexp.MustCompile(`\{([^}/]+)\}`)
+)
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+func (openAPIValidator) Name() string {
+	return "OpenAPI"
+}
+
+func (openAPIValidator) Accepts(doc *assetDocument) bool {
+	root, ok := doc.object()
+	if !ok {
+		return false
+	}
+	_, swagger := root["swagger"]
+	_, openapi := root["openapi"]
+	return swagger || openapi
+}
+
+func (openAPIValidator) Validate(doc *assetDocument) []Problem {
+	c := &checker{doc: doc}
+	root, _ := doc.object()
+
+	// where parameters may be, and whether operations need responses
+	locations := []string{"query", "header", "path", "cookie"}
+	responsesRequired, pathsRequired := true, true
+	if version, ok := root["swagger"]; ok {
+		if fmt.Sprint(version) != "2.0" {
+			c.report([]string{"swagger"}, "swagger must be \"2.0\", not %v", version)
+		}
+		locations = []string{"query", "header", "path", "formData", "body"}
+	} else {
+		version, _ := root["openapi"].(string)
+		if !reOpenAPI3.MatchString(version) {
+			c.report([]string{"openapi"}, "openapi must be a 3.x version like \"3.0.3\", not %v", root["openapi"])
+		}
+		if !strings.HasPrefix(version, "3.0.") {
+			// since 3.1, a document may only hold components or webhooks
+			responsesRequired = false
+			_, components := root["components"]
+			_, webhooks := root["webhooks"]
+			pathsRequired = !components && !webhooks
+		}
+	}
+
+	if info, ok := c.object(root, nil, "info", true); ok {
+		c.str(info, []string{"info"}, "title", true)
+		c.str(info, []string{"info"}, "version", true)
+	}
+
+	operationIds := make(map[string]string)
+	if paths, ok := c.object(root, nil, "paths", pathsRequired); ok {
+		for _, template := range sortedMapKeys(paths) {
+			path := []string{"paths", template}
+			if !strings.HasPrefix(template, "/") {
+				c.report(path, "path %s must start with /", template)
+			}
+			item, ok := c.object(paths, []string{"paths"}, template, true)
+			if !ok {
+				continue
+			}
+			declared, complete := c.openAPIParameters(item, path, locations, template)
+			for _, method := range openAPIMethods {
+				op, ok := c.object(item, path, method, false)
+				if !ok {
+					continue
+				}
+				opPath := at(path, method)
+				opDeclared, opComplete := c.openAPIParameters(op, opPath, locations, template)
+				if complete && opComplete {
+					for _, name := range rePathTemplate.FindAllStringSubmatch(template, -1) {
+						if !declared[name[1]] && !opDeclared[name[1]] {
+							c.report(opPath, "path parameter %s of %s %s is not declared", name[1], strings.ToUpper(method), template)
+						}
+					}
+				}
+				if id, ok := op["operationId"].(string); ok {
+					if other, ok := operationIds[id]; ok {
+						c.report(at(opPath, "operationId"), "operationId %s is already used by %s", id, other)
+					}
+					operationIds[id] = strings.ToUpper(method) + " " + template
+				}
+				if responses, ok := c.object(op, opPath, "responses", responsesRequired); ok && len(responses) == 0 {
+					c.report(at(opPath, "responses"), "%s must list at least one response", pathString(at(opPath, "responses")))
+				}
+			}
+		}
+	}
+
+	c.checkLocalRefs(doc.Value, nil)
+	return c.problems
+}
+
+// openAPIParameters checks the parameters of a path item or operation
+// and returns the names of the path parameters declared. If parameters
+// are referenced, not all of them are known.
+func (c *checker) openAPIParameters(m map[string]interface{}, path []string, locations []string, template string) (map[string]bool, bool) {
+	declared := make(map[string]bool)
+	params, ok := c.array(m, path, "parameters", false)
+	if !ok {
+		return declared, true
+	}
+	complete := true
+	for i, p := range params {
+		pPath := at(path, "parameters", index(i))
+		param, ok := p.(map[string]interface{})
+		if !ok {
+			c.report(pPath, "%s must be an object", pathString(pPath))
+			continue
+		}
+		if _, ok := param["$ref"]; ok {
+			complete = false
+			continue
+		}
+		name, _ := c.str(param, pPath, "name", true)
+		in, ok := c.str(param, pPath, "in", true)
+		if !ok {
+			continue
+		}
+		if !contains(locations, in) {
+			c.report(at(pPath, "in"), "parameter %s must be in one of %s, not %s", name, strings.Join(locations, ", "), in)
+		}
+		if in == "path" {
+			declared[name] = true
+			if required, _ := param["required"].(bool); !required {
+				c.report(pPath, "path parameter %s must be required", name)
+			}
+			if !strings.Contains(template, "{"+name+"}") {
+				c.report(pPath, "path parameter %s does not occur in %s", name, template)
+			}
+		}
+	}
+	return declared, complete
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonSchemaValidator checks JSON Schema documents, i.e. documents with
+// a json-schema.org $schema or named *.schema.json/yaml.
+type jsonSchemaValidator struct{}
+
+var (
+	reSchemaFile     = regexp.MustCompile(`(?i)\.schema\.(json|ya?ml)$`)
+	jsonSchemaTypes  = []string{"string", "number", "integer", "boolean", "object", "array", "null"}
+	schemaKeywords   = []string{"items", "additionalItems", "additionalProperties", "not", "contains", "propertyNames", "if", "then", "else"}
+	schemaArrays     = []string{"allOf", "anyOf", "oneOf"}
+	schemaMaps       = []string{"properties", "patternProperties", "definitions", "$defs"}
+	schemaCounts     = []string{"minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties"}
+	schemaLimitPairs = [][2]string{{"minimum", "maximum"}, {"minLength", "maxLength"}, {"minItems", "maxItems"}, {"minProperties", "maxProperties"}}
+)
+
+func (jsonSchemaValidator) Name() string {
+	return "JSON Schema"
+}
+
+func (jsonSchemaValidator) Accepts(doc *assetDocument) bool {
+	root, ok := doc.object()
+	if !ok {
+		return false
+	}
+	if (openAPIValidator{}).Accepts(doc) {
+		return false
+	}
+	schema, _ := root["$schema"].(string)
+	return strings.Contains(schema, "json-schema.org") || reSchemaFile.MatchString(doc.File)
+}
+
+func (jsonSchemaValidator) Validate(doc *assetDocument) []Problem {
+	c := &checker{doc: doc}
+	c.jsonSchema(doc.Value, nil)
+	c.checkLocalRefs(doc.Value, nil)
+	return c.problems
+}
+
+func (c *checker) jsonSchema(v interface{}, path []string) {
+	if _, ok := v.(bool); ok {
+		return
+	}
+	schema, ok := v.(map[string]interface{})
+	if !ok {
+		c.report(path, "schema %s must be an object or boolean", pathString(path))
+		return
+	}
+
+	switch t := schema["type"].(type) {
+	case nil:
+	case string:
+		if !contains(jsonSchemaTypes, t) {
+			c.report(at(path, "type"), "unknown type %s, use one of %s", t, strings.Join(jsonSchemaTypes, ", "))
+		}
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); !ok || !contains(jsonSchemaTypes, s) {
+				c.report(at(path, "type"), "unknown type %v, use one of %s", item, strings.Join(jsonSchemaTypes, ", "))
+			}
+		}
+	default:
+		c.report(at(path, "type"), "type must be a string or an array of strings")
+	}
+
+	if required, ok := c.array(schema, path, "required", false); ok {
+		for _, r := range required {
+			if _, ok := r.(string); !ok {
+				c.report(at(path, "required"), "required must only list property names, not %v", r)
+			}
+		}
+	}
+	if enum, ok := c.array(schema, path, "enum", false); ok && len(enum) == 0 {
+		c.report(at(path, "enum"), "enum must not be empty")
+	}
+
+	for _, key := range schemaCounts {
+		if n, ok := schema[key]; ok {
+			if f, ok := n.(float64); !ok || f < 0 || f != float64(int(f)) {
+				c.report(at(path, key), "%s must be a non-negative integer", key)
+			}
+		}
+	}
+	if n, ok := schema["multipleOf"]; ok {
+		if f, ok := n.(float64); !ok || f <= 0 {
+			c.report(at(path, "multipleOf"), "multipleOf must be greater than 0")
+		}
+	}
+	for _, pair := range schemaLimitPairs {
+		min, minOk := schema[pair[0]].(float64)
+		max, maxOk := schema[pair[1]].(float64)
+		if minOk && maxOk && min > max {
+			c.report(at(path, pair[0]), "%s must not exceed %s", pair[0], pair[1])
+		}
+	}
+
+	for _, key := range schemaKeywords {
+		if sub, ok := schema[key]; ok {
+			if items, ok := sub.([]interface{}); ok && key == "items" {
+				for i, item := range items {
+					c.jsonSchema(item, at(path, key, index(i)))
+				}
+				continue
+			}
+			c.jsonSchema(sub, at(path, key))
+		}
+	}
+	for _, key := range schemaArrays {
+		if subs, ok := c.array(schema, path, key, false); ok {
+			if len(subs) == 0 {
+				c.report(at(path, key), "%s must not be empty", key)
+			}
+			for i, sub := range subs {
+				c.jsonSchema(sub, at(path, key, index(i)))
+			}
+		}
+	}
+	for _, key := range schemaMaps {
+		if subs, ok := c.object(schema, path, key, false); ok {
+			for _, name := range sortedMapKeys(subs) {
+				c.jsonSchema(subs[name], at(path, key, name))
+			}
+		}
+	}
+}
+
+// ramlValidator checks the structure of RAML 0.8 and 1.0 documents and
+// fragments.
+type ramlValidator struct{}
+
+var (
+	reRamlHeader  = regexp.MustCompile(`^#%RAML (0\.8|1\.0)(?: ([A-Za-z]+))?\s*$`)
+	ramlFragments = []string{"DataType", "Library", "Trait", "ResourceType", "AnnotationTypeDeclaration",
+		"DocumentationItem", "NamedExample", "Overlay", "Extension", "SecurityScheme"}
+	ramlMethods        = []string{"get", "patch", "put", "post", "delete", "head", "options", "trace", "connect"}
+	ramlResourceFacets = []string{"displayName", "description", "type", "is", "securedBy", "uriParameters", "baseUriParameters"}
+	ramlDeclarations   = []string{"types", "securitySchemes", "annotationTypes", "uses"}
+)
+
+func (ramlValidator) Name() string {
+	return "RAML"
+}
+
+func (ramlValidator) Accepts(doc *assetDocument) bool {
+	return true
+}
+
+func (ramlValidator) Validate(doc *assetDocument) []Problem {
+	c := &checker{doc: doc}
+	header := doc.Content
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+	m := reRamlHeader.FindSubmatch(header)
+	if m == nil {
+		c.problems = append(c.problems, Problem{doc.File, 1, 1, "invalid RAML: expected \"#%RAML 1.0\" or \"#%RAML 0.8\", optionally followed by a fragment type"})
+		return c.problems
+	}
+	version, fragment := string(m[1]), string(m[2])
+	if fragment != "" && (version != "1.0" || !contains(ramlFragments, fragment)) {
+		c.problems = append(c.problems, Problem{doc.File, 1, 1, fmt.Sprintf("unknown RAML %s fragment %s", version, fragment)})
+		return c.problems
+	}
+
+	root, ok := doc.object()
+	if !ok {
+		if fragment == "" {
+			c.problems = append(c.problems, Problem{doc.File, 1, 0, "RAML document must be a mapping"})
+		}
+		return c.problems
+	}
+	switch fragment {
+	case "":
+		c.str(root, nil, "title", true)
+	case "Overlay", "Extension":
+		c.str(root, nil, "extends", true)
+	default:
+		// fragments declare a single type, trait etc.
+		return c.problems
+	}
+
+	if v, ok := root["version"]; ok {
+		switch v.(type) {
+		case string, float64:
+		default:
+			c.report([]string{"version"}, "version must be a string or number")
+		}
+	}
+	if v, ok := root["baseUri"]; ok {
+		if _, ok := v.(string); !ok {
+			c.report([]string{"baseUri"}, "baseUri must be a string")
+		}
+	}
+
+	// resource types and traits are maps in 1.0, lists of maps in 0.8
+	resourceTypes := c.ramlDeclared(root, "resourceTypes")
+	traits := c.ramlDeclared(root, "traits")
+	_, usesLibraries := root["uses"]
+	for _, key := range ramlDeclarations {
+		c.object(root, nil, key, false)
+	}
+
+	declared := func(names map[string]bool, name string) bool {
+		// names from libraries are prefixed, e.g. lib.collection
+		return names[name] || (usesLibraries && strings.Contains(name, "."))
+	}
+	for _, key := range sortedMapKeys(root) {
+		if strings.HasPrefix(key, "/") {
+			c.ramlResource(root[key], []string{key}, resourceTypes, traits, declared)
+		}
+	}
+	return c.problems
+}
+
+// ramlDeclared returns the names declared under key at the root.
+func (c *checker) ramlDeclared(root map[string]interface{}, key string) map[string]bool {
+	names := make(map[string]bool)
+	switch v := root[key].(type) {
+	case nil:
+	case map[string]interface{}:
+		for name := range v {
+			names[name] = true
+		}
+	case []interface{}:
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				for name := range m {
+					names[name] = true
+				}
+			}
+		}
+	default:
+		c.report([]string{key}, "%s must be a mapping", key)
+	}
+	return names
+}
+
+// ramlReferences returns the names used by a type or is facet, which
+// may be given as name or as mapping from name to parameters.
+func ramlReferences(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case map[string]interface{}:
+		return sortedMapKeys(v)
+	case []interface{}:
+		names := make([]string, 0)
+		for _, item := range v {
+			names = append(names, ramlReferences(item)...)
+		}
+		return names
+	}
+	return nil
+}
+
+func (c *checker) ramlTraits(m map[string]interface{}, path []string, traits map[string]bool, declared func(map[string]bool, string) bool) {
+	for _, name := range ramlReferences(m["is"]) {
+		if !declared(traits, name) {
+			c.report(at(path, "is"), "trait %s is not declared", name)
+		}
+	}
+}
+
+func (c *checker) ramlResource(v interface{}, path []string, resourceTypes, traits map[string]bool, declared func(map[string]bool, string) bool) {
+	if v == nil {
+		return
+	}
+	resource, ok := v.(map[string]interface{})
+	if !ok {
+		c.report(path, "resource %s must be a mapping", pathString(path))
+		return
+	}
+
+	if t, ok := resource["type"]; ok {
+		names := ramlReferences(t)
+		if len(names) != 1 {
+			c.report(at(path, "type"), "resource %s must have a single type", pathString(path))
+		}
+		for _, name := range names {
+			if !declared(resourceTypes, name) {
+				c.report(at(path, "type"), "resource type %s is not declared", name)
+			}
+		}
+	}
+	c.ramlTraits(resource, path, traits, declared)
+
+	for _, key := range sortedMapKeys(resource) {
+		switch {
+		case strings.HasPrefix(key, "/"):
+			c.ramlResource(resource[key], at(path, key), resourceTypes, traits, declared)
+		case contains(ramlMethods, strings.TrimSuffix(key, "?")):
+			if resource[key] == nil {
+				continue
+			}
+			method, ok := resource[key].(map[string]interface{})
+			if !ok {
+				c.report(at(path, key), "method %s of %s must be a mapping", key, pathString(path))
+				continue
+			}
+			c.ramlTraits(method, at(path, key), traits, declared)
+		case contains(ramlResourceFacets, key), strings.HasPrefix(key, "("):
+			// facets and annotations
+		default:
+			c.report(at(path, key), "unknown property %s of resource %s", key, pathString(path))
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// expectProblems checks that content has exactly the expected problems,
+// given as "line:message portion".
+func expectProblems(t *testing.T, file, content string, expected ...string) {
+	problems := checkAsset([]byte(content), file)
+	if len(problems) != len(expected) {
+		t.Errorf("%s: expected %d problem(s), got %v", file, len(expected), problems)
+		return
+	}
+	for i, p := range problems {
+		parts := strings.SplitN(expected[i], ":", 2)
+		if line := p.String(); !strings.Contains(line, ":"+parts[0]+":") || !strings.Contains(p.Message, parts[1]) {
+			t.Errorf("%s: expected problem %q, got %q", file, expected[i], line)
+		}
+	}
+}
+
+func TestProblemPosition(t *testing.T) {
+	expectProblems(t, "api.json", "{\n  \"a\": 1,\n  \"b\" 2\n}", "3:invalid JSON")
+	expectProblems(t, "api.yaml", "a: 1\nb: [1, 2\n", "2:invalid YAML")
+
+	p := Problem{"api.json", 3, 7, "broken"}
+	if p.String() != "api.json:3:7: broken" {
+		t.Errorf("Unexpected format %q", p.String())
+	}
+}
+
+func TestSwaggerValidator(t *testing.T) {
+	expectProblems(t, "swagger.yaml", `swagger: "2.0"
+info:
+  title: Pets
+  version: "1.0"
+paths:
+  /pets/{id}:
+    get:
+      operationId: getPet
+      parameters:
+        - name: id
+          in: path
+          required: true
+          type: string
+      responses:
+        200:
+          description: A pet
+          schema:
+            $ref: "#/definitions/Pet"
+definitions:
+  Pet:
+    type: object
+`)
+
+	expectProblems(t, "swagger.yaml", `swagger: "2.0"
+info:
+  title: Pets
+paths:
+  /pets/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+        - name: sort
+          in: cookie
+      responses:
+        200:
+          schema:
+            $ref: "#/definitions/Pet"
+  pets:
+    post:
+      responses: {}
+`,
+		"2:info.version is required",
+		"8:path parameter id must be required",
+		"11:parameter sort must be in one of",
+		"15:#/definitions/Pet does not resolve",
+		"16:path pets must start with /",
+		"18:paths.pets.post.responses must list at least one response")
+}
+
+func TestOpenAPI3Validator(t *testing.T) {
+	expectProblems(t, "openapi.json", `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1.0"},
+  "paths": {
+    "/pets/{id}": {
+      "get": {"operationId": "getPet", "responses": {"200": {"description": "A pet"}}},
+      "delete": {"operationId": "getPet", "responses": {"204": {"description": "Gone"}}}
+    }
+  }
+}`,
+		"6:path parameter id of GET /pets/{id} is not declared",
+		"7:path parameter id of DELETE /pets/{id} is not declared",
+		"7:operationId getPet is already used by")
+
+	expectProblems(t, "openapi.yaml", "openapi: 3.1.0\ninfo:\n  title: Pets\n  version: '1.0'\ncomponents: {}\n")
+	expectProblems(t, "openapi.yaml", "openapi: '4'\ninfo:\n  title: Pets\n  version: '1.0'\npaths: {}\n", "1:openapi must be a 3.x version")
+}
+
+func TestJSONSchemaValidator(t *testing.T) {
+	expectProblems(t, "person.json", `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["name"],
+  "properties": {
+    "name": {"type": "string", "minLength": 3, "maxLength": 2},
+    "age": {"type": "int"},
+    "tags": {"type": "array", "items": {"$ref": "#/definitions/tag"}}
+  },
+  "definitions": {"tag": {"enum": []}}
+}`,
+		"6:minLength must not exceed maxLength",
+		"7:unknown type int",
+		"10:enum must not be empty")
+
+	// without $schema, only files named *.schema.json are JSON Schemas
+	expectProblems(t, "person.json", `{"type": "int"}`)
+	expectProblems(t, "person.schema.json", `{"type": "int"}`, "1:unknown type int")
+}
+
+func TestRAMLValidator(t *testing.T) {
+	expectProblems(t, "api.raml", `#%RAML 1.0
+title: Pets
+version: v1
+resourceTypes:
+  collection:
+    get:
+traits:
+  paged:
+    queryParameters:
+      page: integer
+/pets:
+  type: collection
+  is: [paged]
+  /{id}:
+    get:
+      is: [paged]
+`)
+
+	expectProblems(t, "api.raml", `#%RAML 1.0
+version: v1
+/pets:
+  type: collection
+  get:
+    is: [paged]
+  gett:
+`,
+		"1:title is required",
+		"4:resource type collection is not declared",
+		"6:trait paged is not declared",
+		"7:unknown property gett of resource /pets")
+
+	expectProblems(t, "person.raml", "#%RAML 1.0 DataType\ntype: object\n")
+	expectProblems(t, "person.raml", "#%RAML 1.0 Person\ntype: object\n", "1:unknown RAML 1.0 fragment Person")
+	expectProblems(t, "api.raml", "#%RAML 2.0\ntitle: Pets\n", "1:invalid RAML")
+}
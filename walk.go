@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const ignoreFileName = ".slyftignore"
+
+var reAssetFile = regexp.MustCompile(`(?i)\.(json|ya?ml|raml)$`)
+
+// fileFilter selects the files picked up when walking a directory.
+// Patterns are globs matched against the slash separated path relative
+// to the walked directory: `*` and `?` do not match `/`, `**` does. A
+// pattern without `/` matches the name of a file or directory at any
+// depth, one ending in `/` only matches directories. Without include
+// patterns, all JSON, YAML and RAML files are included.
+type fileFilter struct {
+	include []string
+	exclude []string
+}
+
+// readIgnoreFile returns the patterns in dir/.slyftignore, one per line.
+// Blank lines and lines starting with # are skipped.
+func readIgnoreFile(dir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// globRegexp translates a glob to an anchored regular expression.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				re.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				re.WriteString(".*")
+				i++
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+// globMatch reports whether pattern matches rel, see fileFilter.
+func globMatch(pattern, rel string, isDir bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		if !isDir {
+			return false
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	name := rel
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(rel)
+	}
+	re, err := globRegexp(strings.TrimPrefix(pattern, "/"))
+	if err != nil {
+		Log.Debugf("Ignoring pattern %s: %v", pattern, err)
+		return false
+	}
+	return re.MatchString(name)
+}
+
+func matchesAny(patterns []string, rel string, isDir bool) bool {
+	for _, pattern := range patterns {
+		if globMatch(pattern, rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fileFilter) excludes(rel string, isDir bool) bool {
+	return matchesAny(f.exclude, rel, isDir)
+}
+
+func (f *fileFilter) includes(rel string) bool {
+	if len(f.include) == 0 {
+		return reAssetFile.MatchString(rel)
+	}
+	return matchesAny(f.include, rel, false)
+}
+
+// selects reports whether a walk with the filter picks up the file at
+// rel, the slash separated path relative to the walked directory.
+func (f *fileFilter) selects(rel string) bool {
+	if path.IsAbs(rel) {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i, part := range parts {
+		if part == "" || strings.HasPrefix(part, ".") || f.excludes(strings.Join(parts[:i+1], "/"), i < len(parts)-1) {
+			return false
+		}
+	}
+	return f.includes(rel)
+}
+
+// withIgnoreFile returns filter, also excluding the patterns in
+// dir/.slyftignore.
+func withIgnoreFile(dir string, filter *fileFilter) (*fileFilter, error) {
+	ignored, err := readIgnoreFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &fileFilter{filter.include, append(append([]string{}, filter.exclude...), ignored...)}, nil
+}
+
+// walkAssetFiles returns the files below dir selected by filter and by
+// dir/.slyftignore, keyed by their slash separated path relative to dir.
+// Hidden files and directories are skipped.
+func walkAssetFiles(dir string, filter *fileFilter) (map[string]string, error) {
+	filter, err := withIgnoreFile(dir, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string)
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if strings.HasPrefix(info.Name(), ".") || filter.excludes(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && filter.includes(rel) {
+			files[rel] = p
+		}
+		return nil
+	})
+	return files, err
+}
+
+// sortedKeys returns the relative paths found by walkAssetFiles in order.
+func sortedKeys(files map[string]string) []string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern, rel string
+		isDir, match bool
+	}{
+		{"*.raml", "api.raml", false, true},
+		{"*.raml", "fragments/types/person.raml", false, true},
+		{"*.raml", "api.json", false, false},
+		{"fragments/*.raml", "fragments/person.raml", false, true},
+		{"fragments/*.raml", "fragments/types/person.raml", false, false},
+		{"fragments/**/*.raml", "fragments/types/person.raml", false, true},
+		{"fragments/**/*.raml", "fragments/person.raml", false, true},
+		{"/api.json", "api.json", false, true},
+		{"/api.json", "v1/api.json", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"v[12].json", "v2.json", false, true},
+		{"v[!12].json", "v2.json", false, false},
+		{"draft-?.yaml", "draft-1.yaml", false, true},
+	}
+	for _, c := range cases {
+		if globMatch(c.pattern, c.rel, c.isDir) != c.match {
+			t.Errorf("Pattern %q on %q (dir: %v) must give %v", c.pattern, c.rel, c.isDir, c.match)
+		}
+	}
+}
+
+func TestWalkAssetFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slyft-walk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"api.raml":                   "#%RAML 1.0\n",
+		"README.md":                  "docs",
+		"fragments/person.raml":      "#%RAML 1.0 DataType\n",
+		"fragments/draft/wip.raml":   "#%RAML 1.0 DataType\n",
+		"fragments/types/addr.json":  "{}",
+		".git/config.json":           "{}",
+		"node_modules/pkg/spec.json": "{}",
+		ignoreFileName:               "# generated\nnode_modules/\ndraft/\n",
+	} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0755)
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := walkAssetFiles(dir, &fileFilter{})
+	if err != nil {
+		t.Fatalf("Must walk %s: %v", dir, err)
+	}
+	expected := []string{"api.raml", "fragments/person.raml", "fragments/types/addr.json"}
+	if !reflect.DeepEqual(sortedKeys(files), expected) {
+		t.Errorf("Expected %v, got %v", expected, sortedKeys(files))
+	}
+
+	files, _ = walkAssetFiles(dir, &fileFilter{include: []string{"*.raml"}, exclude: []string{"/api.raml"}})
+	expected = []string{"fragments/person.raml"}
+	if !reflect.DeepEqual(sortedKeys(files), expected) {
+		t.Errorf("Must apply include and exclude, expected %v, got %v", expected, sortedKeys(files))
+	}
+}
+
+func TestFileFilterSelects(t *testing.T) {
+	filter := &fileFilter{exclude: []string{"node_modules/", "draft.json"}}
+	cases := []struct {
+		rel     string
+		selects bool
+	}{
+		{"api.json", true},
+		{"fragments/person.raml", true},
+		{"draft.json", false},
+		{"specs/draft.json", false},
+		{"node_modules/pkg/spec.json", false},
+		{".hidden.json", false},
+		{".git/config.json", false},
+		{"README.md", false},
+		{"../api.json", false},
+		{"/tmp/api.json", false},
+	}
+	for _, c := range cases {
+		if filter.selects(c.rel) != c.selects {
+			t.Errorf("%s: must give %v", c.rel, c.selects)
+		}
+	}
+}
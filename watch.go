@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	cli "github.com/jawher/mow.cli"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	// watchDebounce is the quiet time after a change before the assets
+	// are uploaded, so saving several files at once validates once.
+	watchDebounce = 300 * time.Millisecond
+	// watchPollInterval is how often files are checked where changes
+	// are not notified by the system.
+	watchPollInterval = time.Second
+)
+
+// watchChanges sends on the returned channel whenever files below dir
+// may have changed, until stop is closed; then the channel is closed.
+// Changes may be coalesced.
+// Platforms with change notifications replace it, see watch_linux.go.
+var watchChanges = pollChanges
+
+// watchInterrupt returns a channel closed when the user stops watching.
+var watchInterrupt = func() <-chan struct{} {
+	done := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		signal.Stop(sig)
+		close(done)
+	}()
+	return done
+}
+
+// notifyChange sends on changes without blocking; a change already
+// pending covers this one.
+func notifyChange(changes chan<- struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func stampAssetFiles(dir string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	files, err := localAssetFiles(dir)
+	if err != nil {
+		Log.Debugf("Unable to read %s: %v", dir, err)
+		return stamps
+	}
+	for name, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[name] = fileStamp{info.Size(), info.ModTime()}
+		}
+	}
+	return stamps
+}
+
+// pollChanges is watchChanges by comparing size and time of the asset
+// files every watchPollInterval.
+func pollChanges(dir string, stop <-chan struct{}) (<-chan struct{}, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		last := stampAssetFiles(dir)
+		ticker := time.NewTicker(watchPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			stamps := stampAssetFiles(dir)
+			if len(stamps) != len(last) {
+				notifyChange(changes)
+			} else {
+				for name, stamp := range stamps {
+					if last[name] != stamp {
+						notifyChange(changes)
+						break
+					}
+				}
+			}
+			last = stamps
+		}
+	}()
+	return changes, nil
+}
+
+// uploadChanges uploads the files in dir whose content differs from what
+// was last uploaded from them, named like sync does, and returns how
+// many were uploaded and the first failure. Assets are never deleted
+// while watching.
+func uploadChanges(dir string, p *Project) (int, error) {
+	files, err := localAssetFiles(dir)
+	if err != nil {
+		return 0, ReportError("Reading "+dir, err)
+	}
+	ps := readState(dir).project(p.ID)
+	uploaded := 0
+	var first error
+	for _, name := range sortedKeys(files) {
+		digest, err := fileDigest(files[name])
+		if err == nil && digest == ps.digest(name) {
+			continue
+		}
+		if err != nil {
+			ReportError("Reading "+files[name], err)
+		} else {
+			err = readFileAndPostNamedAsset(dir, files[name], name, p, true)
+		}
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		uploaded++
+	}
+	return uploaded, first
+}
+
+// watchValidate validates the project and waits for the result, unless
+// stop is closed meanwhile.
+func watchValidate(p *Project, stop <-chan struct{}) (*Job, error) {
+	job, err := startJob("validate", nil, p, true)
+	if err != nil {
+		return nil, err
+	}
+	for interval := followInterval; !job.finished(); {
+		select {
+		case <-stop:
+			return nil, nil
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > followMaxInterval {
+			interval = followMaxInterval
+		}
+		next, err := fetchJob(job)
+		if err != nil {
+			return nil, ReportError("Following the job", err)
+		}
+		job = next
+	}
+	return job, nil
+}
+
+// watchResultLimit is the number of diagnostics shown after a validation.
+const watchResultLimit = 10
+
+// displayWatchResult prints the result of a validation condensed to a
+// line and the first diagnostics. On a terminal, the screen is cleared
+// first, so the latest result is always in place.
+func displayWatchResult(job *Job, dir string) {
+	if structuredOutput() {
+		displayStructured(job)
+		return
+	}
+	if terminal.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Print("\033[H\033[2J")
+	}
+
+	diagnostics := job.Diagnostics()
+	counts := make(map[string]int)
+	for _, d := range diagnostics {
+		counts[d.Severity]++
+	}
+	result := "OK"
+	if job.failed() {
+		result = "FAILED"
+	}
+	fmt.Printf("%s validate job %d: %s, %d error(s), %d warning(s)\n",
+		time.Now().Format("15:04:05"), job.ID, result, counts[severityError], counts[severityWarning])
+	if job.failed() && len(diagnostics) == 0 && job.Results.ResultMessage != "" {
+		fmt.Printf("  %s\n", job.Results.ResultMessage)
+	}
+	for i, d := range diagnostics {
+		if i == watchResultLimit {
+			fmt.Printf("  ... and %d more, see `slyft job show %d`\n", len(diagnostics)-i, job.ID)
+			break
+		}
+		fmt.Printf("  %s\n", d)
+	}
+	fmt.Printf("Watching %s, press Ctrl-C to stop\n", dir)
+}
+
+// watchRound uploads the changes and, if there were any (or always if
+// force is set), validates the project. It returns the first failure of
+// the round, or last, the outcome of the previous round, if it did
+// nothing, so slyft exits with the outcome of the last round which did
+// something.
+func watchRound(dir string, p *Project, force bool, last error, stop <-chan struct{}) error {
+	uploaded, err := uploadChanges(dir, p)
+	if uploaded == 0 && !force {
+		if err == nil {
+			err = last
+		}
+		return err
+	}
+	job, validateErr := watchValidate(p, stop)
+	if job != nil {
+		displayWatchResult(job, dir)
+		validateErr = job.err()
+	}
+	if err == nil {
+		err = validateErr
+	}
+	return err
+}
+
+func watchProject(cmd *cli.Cmd) {
+	cmd.Spec = "[--project] [DIR]"
+	name := cmd.StringOpt("project p", "", "Name (or part of it) of a project")
+	dir := cmd.StringArg("DIR", ".", "Directory holding the asset files")
+
+	cmd.Action = action(func() error {
+		*name = strings.TrimSpace(*name)
+		if *name == "" {
+			*name, _ = ReadProjectLock()
+		}
+		p, err := chooseProject(*name, "Watch assets of: ")
+		if err != nil {
+			return ReportError("Choosing the project", err)
+		}
+
+		stop := watchInterrupt()
+		changes, err := watchChanges(*dir, stop)
+		if err != nil {
+			return ReportError("Watching "+*dir, err)
+		}
+		// let the watcher finish before the command does
+		defer func() {
+			for range changes {
+			}
+		}()
+
+		// start with the changes made while not watching
+		last := watchRound(*dir, p, true, nil, stop)
+		for {
+			select {
+			case <-stop:
+				return last
+			case _, ok := <-changes:
+				if !ok {
+					return last
+				}
+			}
+			// wait until the files are quiet for a while
+			for quiet := false; !quiet; {
+				select {
+				case <-stop:
+					return last
+				case <-changes:
+				case <-time.After(watchDebounce):
+					quiet = true
+				}
+			}
+			last = watchRound(*dir, p, false, last, stop)
+		}
+	})
+}
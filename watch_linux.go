@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+func init() {
+	watchChanges = inotifyChanges
+}
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// inotifyChanges is watchChanges using inotify. Every directory below
+// dir is watched, including those created later. Changes of hidden
+// files, like .slyftstate, are left out.
+func inotifyChanges(dir string, stop <-chan struct{}) (<-chan struct{}, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		Log.Debugf("Unable to use inotify, polling: %v", err)
+		return pollChanges(dir, stop)
+	}
+	// non-blocking, so closing it ends a pending Read
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	dirs := make(map[int32]string)
+	addDirs := func(root string) error {
+		return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			if p != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			wd, err := syscall.InotifyAddWatch(fd, p, inotifyMask)
+			if err != nil {
+				return err
+			}
+			dirs[int32(wd)] = p
+			return nil
+		})
+	}
+	if err := addDirs(dir); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		<-stop
+		f.Close()
+	}()
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				Log.Debugf("Stopped watching %s: %v", dir, err)
+				close(changes)
+				return
+			}
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+				name := strings.TrimRight(string(nameBytes), "\x00")
+				offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+				if name == "" || strings.HasPrefix(name, ".") {
+					continue
+				}
+				if event.Mask&syscall.IN_ISDIR != 0 && event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					if parent, ok := dirs[event.Wd]; ok {
+						if err := addDirs(filepath.Join(parent, name)); err != nil {
+							Log.Debugf("Unable to watch %s: %v", name, err)
+						}
+					}
+				}
+				notifyChange(changes)
+			}
+		}
+	}()
+	return changes, nil
+}